@@ -2,9 +2,16 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/CycleZero/mc-yggdrasil-go/models"
 	"github.com/CycleZero/mc-yggdrasil-go/service"
@@ -38,13 +45,13 @@ func NewLocalClient(service service.YggdrasilService) *YggdrasilClient {
 
 // Auth 执行认证请求
 // 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
-func (c *YggdrasilClient) Auth(req models.AuthRequest) (*models.AuthResponse, error) {
+func (c *YggdrasilClient) Auth(ctx context.Context, req models.AuthRequest) (*models.AuthResponse, error) {
 	if c.LocalService != nil {
-		return c.LocalService.Auth(req)
+		return c.LocalService.Auth(ctx, req)
 	}
 
 	url := c.BaseURL + "/authserver/authenticate"
-	resp, err := c.doPostRequest(url, req)
+	resp, err := c.doPostRequest(ctx, url, req)
 	if err != nil {
 		return nil, err
 	}
@@ -59,13 +66,13 @@ func (c *YggdrasilClient) Auth(req models.AuthRequest) (*models.AuthResponse, er
 
 // Refresh 刷新访问令牌
 // 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
-func (c *YggdrasilClient) Refresh(req models.RefreshRequest) (*models.AuthResponse, error) {
+func (c *YggdrasilClient) Refresh(ctx context.Context, req models.RefreshRequest) (*models.AuthResponse, error) {
 	if c.LocalService != nil {
-		return c.LocalService.Refresh(req)
+		return c.LocalService.Refresh(ctx, req)
 	}
 
 	url := c.BaseURL + "/authserver/refresh"
-	resp, err := c.doPostRequest(url, req)
+	resp, err := c.doPostRequest(ctx, url, req)
 	if err != nil {
 		return nil, err
 	}
@@ -80,20 +87,19 @@ func (c *YggdrasilClient) Refresh(req models.RefreshRequest) (*models.AuthRespon
 
 // Validate 验证访问令牌是否有效
 // 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
-func (c *YggdrasilClient) Validate(req models.ValidateRequest) (bool, error) {
+func (c *YggdrasilClient) Validate(ctx context.Context, req models.ValidateRequest) (bool, error) {
 	if c.LocalService != nil {
-		return c.LocalService.Validate(req)
+		return c.LocalService.Validate(ctx, req)
 	}
 
 	url := c.BaseURL + "/authserver/validate"
-	respData, err := c.doPostRequest(url, req)
+	_, err := c.doPostRequest(ctx, url, req)
 
-	// 成功验证时返回204 No Content，失败时返回错误
+	// 成功验证时返回204 No Content，令牌无效时与服务层一致返回false
+	if errors.Is(err, service.ErrInvalidToken) {
+		return false, nil
+	}
 	if err != nil {
-		var apiErr models.ErrorResponse
-		if jsonErr := json.Unmarshal(respData, &apiErr); jsonErr == nil {
-			return false, errors.New(apiErr.ErrorMessage)
-		}
 		return false, err
 	}
 
@@ -102,30 +108,181 @@ func (c *YggdrasilClient) Validate(req models.ValidateRequest) (bool, error) {
 
 // Invalidate 使访问令牌失效（登出）
 // 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
-func (c *YggdrasilClient) Invalidate(req models.InvalidateRequest) error {
+func (c *YggdrasilClient) Invalidate(ctx context.Context, req models.InvalidateRequest) error {
 	if c.LocalService != nil {
-		return c.LocalService.Invalidate(req)
+		return c.LocalService.Invalidate(ctx, req)
 	}
 
 	url := c.BaseURL + "/authserver/invalidate"
-	_, err := c.doPostRequest(url, req)
+	_, err := c.doPostRequest(ctx, url, req)
 	return err
 }
 
 // Signout 使用用户名和密码登出
 // 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
-func (c *YggdrasilClient) Signout(req models.SignoutRequest) error {
+func (c *YggdrasilClient) Signout(ctx context.Context, req models.SignoutRequest) error {
 	if c.LocalService != nil {
-		return c.LocalService.Signout(req)
+		return c.LocalService.Signout(ctx, req)
 	}
 
 	url := c.BaseURL + "/authserver/signout"
-	_, err := c.doPostRequest(url, req)
+	_, err := c.doPostRequest(ctx, url, req)
+	return err
+}
+
+// Join 通知Yggdrasil服务器客户端即将进入服务器
+// 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
+func (c *YggdrasilClient) Join(ctx context.Context, req models.JoinRequest) error {
+	if c.LocalService != nil {
+		return c.LocalService.Join(ctx, req)
+	}
+
+	url := c.BaseURL + "/sessionserver/session/minecraft/join"
+	_, err := c.doPostRequest(ctx, url, req)
+	return err
+}
+
+// HasJoined 检查客户端是否已进入服务器，供游戏服务端调用
+// 验证失败（未找到匹配的记录）时返回nil
+// 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
+func (c *YggdrasilClient) HasJoined(ctx context.Context, username, serverID, ip string) (*models.Profile, error) {
+	if c.LocalService != nil {
+		return c.LocalService.HasJoined(ctx, username, serverID, ip)
+	}
+
+	query := url.Values{}
+	query.Set("username", username)
+	query.Set("serverId", serverID)
+	if ip != "" {
+		query.Set("ip", ip)
+	}
+	resp, err := c.doGetRequest(ctx, c.BaseURL+"/sessionserver/session/minecraft/hasJoined?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	// 成功时返回角色信息，失败时返回204 No Content
+	if len(resp) == 0 {
+		return nil, nil
+	}
+
+	var profile models.Profile
+	if err := json.Unmarshal(resp, &profile); err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// GetProfile 根据UUID查询角色，角色不存在时返回nil
+// 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
+func (c *YggdrasilClient) GetProfile(ctx context.Context, id string, unsigned bool) (*models.Profile, error) {
+	if c.LocalService != nil {
+		return c.LocalService.GetProfile(ctx, id, unsigned)
+	}
+
+	query := url.Values{}
+	query.Set("unsigned", strconv.FormatBool(unsigned))
+	resp, err := c.doGetRequest(ctx, c.BaseURL+"/sessionserver/session/minecraft/profile/"+url.PathEscape(id)+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	// 角色不存在时返回204 No Content
+	if len(resp) == 0 {
+		return nil, nil
+	}
+
+	var profile models.Profile
+	if err := json.Unmarshal(resp, &profile); err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// GetProfilesByNames 根据名称批量查询角色，不存在的角色会被忽略
+// 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
+func (c *YggdrasilClient) GetProfilesByNames(ctx context.Context, names []string) ([]models.Profile, error) {
+	if c.LocalService != nil {
+		return c.LocalService.GetProfilesByNames(ctx, names)
+	}
+
+	url := c.BaseURL + "/api/profiles/minecraft"
+	resp, err := c.doPostRequest(ctx, url, names)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []models.Profile
+	if err := json.Unmarshal(resp, &profiles); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// UploadTexture 上传角色的材质
+// 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
+func (c *YggdrasilClient) UploadTexture(ctx context.Context, accessToken, profileID string, textureType models.TextureType, model models.TextureModel, data []byte) error {
+	if c.LocalService != nil {
+		return c.LocalService.UploadTexture(ctx, accessToken, profileID, textureType, model, data)
+	}
+
+	// 构建multipart表单
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if model == models.TextureModelSlim {
+		if err := form.WriteField("model", string(model)); err != nil {
+			return err
+		}
+	}
+	file, err := form.CreateFormFile("file", strings.ToLower(string(textureType))+".png")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.textureURL(profileID, textureType), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	_, err = c.doRequest(req)
 	return err
 }
 
+// DeleteTexture 删除角色的材质
+// 如果配置了本地服务，则使用本地服务；否则发送HTTP请求
+func (c *YggdrasilClient) DeleteTexture(ctx context.Context, accessToken, profileID string, textureType models.TextureType) error {
+	if c.LocalService != nil {
+		return c.LocalService.DeleteTexture(ctx, accessToken, profileID, textureType)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.textureURL(profileID, textureType), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	_, err = c.doRequest(req)
+	return err
+}
+
+// textureURL 返回角色材质的上传和删除地址
+func (c *YggdrasilClient) textureURL(profileID string, textureType models.TextureType) string {
+	return c.BaseURL + "/api/user/profile/" + url.PathEscape(profileID) + "/" + strings.ToLower(string(textureType))
+}
+
 // doPostRequest 执行HTTP POST请求并返回响应内容
-func (c *YggdrasilClient) doPostRequest(url string, body interface{}) ([]byte, error) {
+func (c *YggdrasilClient) doPostRequest(ctx context.Context, url string, body interface{}) ([]byte, error) {
 	// 序列化请求体
 	jsonData, err := json.Marshal(body)
 	if err != nil {
@@ -133,7 +290,7 @@ func (c *YggdrasilClient) doPostRequest(url string, body interface{}) ([]byte, e
 	}
 
 	// 创建HTTP请求
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
@@ -141,6 +298,24 @@ func (c *YggdrasilClient) doPostRequest(url string, body interface{}) ([]byte, e
 	// 设置请求头
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
+	return c.doRequest(req)
+}
+
+// doGetRequest 执行HTTP GET请求并返回响应内容
+func (c *YggdrasilClient) doGetRequest(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.doRequest(req)
+}
+
+// maxResponseSize 响应体的最大长度
+const maxResponseSize = 1 << 20
+
+// doRequest 发送HTTP请求并返回响应内容，非2xx的响应返回*APIError
+func (c *YggdrasilClient) doRequest(req *http.Request) ([]byte, error) {
 	// 发送请求
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -148,22 +323,23 @@ func (c *YggdrasilClient) doPostRequest(url string, body interface{}) ([]byte, e
 	}
 	defer resp.Body.Close()
 
-	// 读取响应体
-	respBody := make([]byte, 1024*1024) // 限制1MB
-	n, err := resp.Body.Read(respBody)
-	if err != nil && err.Error() != "EOF" {
-		return nil, err
-	}
-	respBody = respBody[:n]
+	// 读取完整的响应体
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
 
-	// 检查响应状态码
+	// 检查响应状态码，错误响应的内容过长或无法读取时仍返回状态码，调用者据此区分服务器故障
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// 尝试解析错误响应
-		var apiErr models.ErrorResponse
-		if jsonErr := json.Unmarshal(respBody, &apiErr); jsonErr == nil {
-			return respBody, errors.New(apiErr.ErrorMessage)
+		if err != nil || len(respBody) > maxResponseSize {
+			return nil, &APIError{StatusCode: resp.StatusCode}
 		}
-		return respBody, errors.New("request failed with status code: " + resp.Status)
+		return respBody, newAPIError(resp.StatusCode, respBody)
+	}
+
+	// 成功响应的内容超过上限时返回错误而不是截断
+	if err != nil {
+		return nil, err
+	}
+	if len(respBody) > maxResponseSize {
+		return nil, fmt.Errorf("response body exceeds %d bytes", maxResponseSize)
 	}
 
 	return respBody, nil
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+	"github.com/CycleZero/mc-yggdrasil-go/service"
+)
+
+// APIError 表示服务器返回的错误响应
+// 保留了状态码和错误响应的全部字段，调用者可以区分凭证错误、账号迁移和服务器故障等情况
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E9%94%99%E8%AF%AF%E4%BF%A1%E6%81%AF%E6%A0%BC%E5%BC%8F
+
+type APIError struct {
+	StatusCode   int    // HTTP状态码
+	ErrorType    string // 错误的简要描述（机器可读），对应error字段，响应不是错误信息格式时为空
+	ErrorMessage string // 错误的详细信息（人类可读），对应errorMessage字段
+	Cause        string // 该错误的原因（可选），对应cause字段
+}
+
+// newAPIError 根据状态码和响应体创建APIError
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	var errResp models.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		apiErr.ErrorType = errResp.Error
+		apiErr.ErrorMessage = errResp.ErrorMessage
+		apiErr.Cause = errResp.Cause
+	}
+	return apiErr
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	if e.ErrorMessage != "" {
+		return e.ErrorMessage
+	}
+	if e.ErrorType != "" {
+		return e.ErrorType
+	}
+	return "request failed with status code: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+}
+
+// Unwrap 将错误响应还原为服务层的错误，以便调用者使用errors.Is判断
+func (e *APIError) Unwrap() error {
+	if e.ErrorType == "" {
+		return nil
+	}
+	return service.ErrorFromResponse(e.StatusCode, models.ErrorResponse{
+		Error:        e.ErrorType,
+		ErrorMessage: e.ErrorMessage,
+		Cause:        e.Cause,
+	})
+}
@@ -2,4 +2,7 @@ module github.com/CycleZero/mc-yggdrasil-go
 
 go 1.24
 
-require github.com/google/uuid v1.6.0 // indirect
+require (
+	github.com/google/uuid v1.6.0
+	github.com/mattn/go-sqlite3 v1.14.33
+)
@@ -44,6 +44,24 @@ const (
 	TextureCape TextureType = "CAPE"
 )
 
+// Texture 表示textures属性中的单个材质
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#textures-%E6%9D%90%E8%B4%A8%E4%BF%A1%E6%81%AF%E5%B1%9E%E6%80%A7
+
+type Texture struct {
+	URL      string            `json:"url"`                // 材质的URL
+	Metadata map[string]string `json:"metadata,omitempty"` // 材质的元数据（可选）
+}
+
+// TexturesPayload 表示textures属性的值（Base64编码前）
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#textures-%E6%9D%90%E8%B4%A8%E4%BF%A1%E6%81%AF%E5%B1%9E%E6%80%A7
+
+type TexturesPayload struct {
+	Timestamp   int64                   `json:"timestamp"`   // 该属性值被生成时的时间戳（毫秒）
+	ProfileID   string                  `json:"profileId"`   // 所有者的UUID（无符号）
+	ProfileName string                  `json:"profileName"` // 所有者的名称
+	Textures    map[TextureType]Texture `json:"textures"`    // 角色的材质
+}
+
 // TextureModel 表示材质模型
 // https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E8%A7%92%E8%89%B2profile
 
@@ -110,3 +128,45 @@ type SignoutRequest struct {
 	Username string `json:"username"` // 用户名（邮箱）
 	Password string `json:"password"` // 密码
 }
+
+// JoinRequest 表示客户端进入服务器请求
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E5%AE%A2%E6%88%B7%E7%AB%AF%E8%BF%9B%E5%85%A5%E6%9C%8D%E5%8A%A1%E5%99%A8
+
+type JoinRequest struct {
+	AccessToken     string `json:"accessToken"`     // 访问令牌
+	SelectedProfile string `json:"selectedProfile"` // 该令牌所绑定的角色的UUID（无符号）
+	ServerID        string `json:"serverId"`        // 服务端发送给客户端的serverId
+}
+
+// APIMetadata 表示API元数据
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#api%E5%85%83%E6%95%B0%E6%8D%AE%E8%8E%B7%E5%8F%96
+
+type APIMetadata struct {
+	Meta               MetadataMeta `json:"meta"`               // 服务端的元数据
+	SkinDomains        []string     `json:"skinDomains"`        // 材质域名白名单
+	SignaturePublicKey string       `json:"signaturePublickey"` // 用于验证数字签名的公钥（PEM格式）
+}
+
+// MetadataMeta 表示API元数据中的服务端元数据
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#meta-%E4%B8%AD%E7%9A%84%E5%85%83%E6%95%B0%E6%8D%AE
+
+type MetadataMeta struct {
+	ServerName            string        `json:"serverName,omitempty"`            // 服务器名称
+	ImplementationName    string        `json:"implementationName,omitempty"`    // 服务端实现的名称
+	ImplementationVersion string        `json:"implementationVersion,omitempty"` // 服务端实现的版本
+	Links                 MetadataLinks `json:"links"`                           // 相关链接
+
+	FeatureNonEmailLogin            bool `json:"feature.non_email_login,omitempty"`             // 是否支持使用邮箱之外的凭证登录
+	FeatureLegacySkinAPI            bool `json:"feature.legacy_skin_api,omitempty"`             // 是否支持旧式皮肤API
+	FeatureNoMojangNamespace        bool `json:"feature.no_mojang_namespace,omitempty"`         // 是否禁用authlib-injector的Mojang命名空间
+	FeatureEnableMojangAntiFeatures bool `json:"feature.enable_mojang_anti_features,omitempty"` // 是否开启Minecraft的anti-features
+	FeatureEnableProfileKey         bool `json:"feature.enable_profile_key,omitempty"`          // 是否支持Minecraft的消息签名密钥对功能
+	FeatureUsernameCheck            bool `json:"feature.username_check,omitempty"`              // 是否开启用户名验证
+}
+
+// MetadataLinks 表示API元数据中的相关链接
+
+type MetadataLinks struct {
+	Homepage string `json:"homepage,omitempty"` // 验证服务器首页地址
+	Register string `json:"register,omitempty"` // 注册页面地址
+}
@@ -0,0 +1,93 @@
+package passwords
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"hash"
+	"strings"
+)
+
+// Verifier 验证其他系统导入的旧版密码哈希
+// 旧版哈希验证成功后，服务层会将其重新计算为本系统的格式
+
+type Verifier interface {
+	// Match 检查哈希值是否为该验证器支持的格式
+	Match(encoded string) bool
+
+	// Verify 验证密码是否与哈希值匹配
+	Verify(encoded, password string) (bool, error)
+}
+
+// DefaultVerifiers 返回默认支持的旧版格式：AuthMe和Blessing Skin
+// 明文密码不在其中，需要时显式添加Plaintext
+func DefaultVerifiers() []Verifier {
+	return []Verifier{
+		AuthMeSHA256,
+		BlessingSkinSalted2SHA256,
+		BlessingSkinSalted2MD5,
+	}
+}
+
+// 支持的旧版格式
+var (
+	// AuthMeSHA256 AuthMe默认的SHA256格式：$SHA$盐$哈希值，哈希值为 sha256(sha256(密码) + 盐)
+	AuthMeSHA256 Verifier = &saltedDoubleHashVerifier{prefix: "$SHA$", hash: sha256.New}
+
+	// BlessingSkinSalted2SHA256 Blessing Skin的SALTED2SHA256格式，导入时需写为 $SALTED2SHA256$盐$哈希值
+	// 哈希值为 sha256(sha256(密码) + 盐)，盐为Blessing Skin配置中的SALT
+	BlessingSkinSalted2SHA256 Verifier = &saltedDoubleHashVerifier{prefix: "$SALTED2SHA256$", hash: sha256.New}
+
+	// BlessingSkinSalted2MD5 Blessing Skin的SALTED2MD5格式，导入时需写为 $SALTED2MD5$盐$哈希值
+	// 哈希值为 md5(md5(密码) + 盐)，盐为Blessing Skin配置中的SALT
+	BlessingSkinSalted2MD5 Verifier = &saltedDoubleHashVerifier{prefix: "$SALTED2MD5$", hash: md5.New}
+
+	// Plaintext 明文密码（不以$开头的值），用于升级早期版本保存的明文密码
+	// 缺少前缀的哈希值会被当作明文密码接受，只应在存储中确实有明文密码时临时启用
+	Plaintext Verifier = plaintextVerifier{}
+)
+
+// saltedDoubleHashVerifier 验证 前缀盐$哈希值 格式的哈希，哈希值为 H(H(密码)的十六进制 + 盐)的十六进制
+
+type saltedDoubleHashVerifier struct {
+	prefix string
+	hash   func() hash.Hash
+}
+
+// Match 实现检查哈希值格式
+func (v *saltedDoubleHashVerifier) Match(encoded string) bool {
+	return strings.HasPrefix(encoded, v.prefix)
+}
+
+// Verify 实现验证密码
+func (v *saltedDoubleHashVerifier) Verify(encoded, password string) (bool, error) {
+	salt, expected, ok := strings.Cut(strings.TrimPrefix(encoded, v.prefix), "$")
+	if !ok || expected == "" {
+		return false, ErrMalformedHash
+	}
+
+	actual := v.hexHash(v.hexHash(password) + salt)
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(strings.ToLower(expected))) == 1, nil
+}
+
+// hexHash 计算字符串的哈希值并返回小写十六进制编码
+func (v *saltedDoubleHashVerifier) hexHash(s string) string {
+	h := v.hash()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// plaintextVerifier 验证明文密码
+
+type plaintextVerifier struct{}
+
+// Match 实现检查哈希值格式
+func (plaintextVerifier) Match(encoded string) bool {
+	return !strings.HasPrefix(encoded, "$")
+}
+
+// Verify 实现验证密码
+func (plaintextVerifier) Verify(encoded, password string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(encoded), []byte(password)) == 1, nil
+}
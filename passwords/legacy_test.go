@@ -0,0 +1,59 @@
+package passwords
+
+import (
+	"errors"
+	"testing"
+)
+
+// 已知答案的测试向量，哈希值由独立的实现计算
+var legacyVectors = []struct {
+	name     string
+	encoded  string
+	password string
+}{
+	{"AuthMe", "$SHA$a1b2c3d4e5f60718$595dc040c754767cb8e7df83602fd82e893684fcf9f84e19716d583df3730063", "correct horse"},
+	{"BlessingSkinSalted2SHA256", "$SALTED2SHA256$BSsalt$4818954780c108afebf0e7faffe9212dbee27a6ab95dd687b9c9815446e96e12", "secret123"},
+	{"BlessingSkinSalted2MD5", "$SALTED2MD5$BSsalt$b249ead3b27a899ca49df21318327ccb", "secret123"},
+	{"BlessingSkinSalted2MD5Uppercase", "$SALTED2MD5$BSsalt$B249EAD3B27A899CA49DF21318327CCB", "secret123"},
+}
+
+func TestLegacyVerifiers(t *testing.T) {
+	h := NewHasher()
+	for _, v := range legacyVectors {
+		t.Run(v.name, func(t *testing.T) {
+			ok, rehash, err := h.Verify(v.encoded, v.password)
+			if err != nil || !ok || !rehash {
+				t.Fatalf("Verify(correct password) = %v, %v, %v, want true, true, nil", ok, rehash, err)
+			}
+			ok, rehash, err = h.Verify(v.encoded, v.password+"x")
+			if err != nil || ok || rehash {
+				t.Fatalf("Verify(wrong password) = %v, %v, %v, want false, false, nil", ok, rehash, err)
+			}
+		})
+	}
+}
+
+func TestLegacyMalformedHash(t *testing.T) {
+	h := NewHasher()
+	for _, encoded := range []string{"$SHA$saltonly", "$SALTED2SHA256$salt$"} {
+		if _, _, err := h.Verify(encoded, "secret"); !errors.Is(err, ErrMalformedHash) {
+			t.Errorf("Verify(%q) error = %v, want ErrMalformedHash", encoded, err)
+		}
+	}
+}
+
+func TestPlaintextNotDefault(t *testing.T) {
+	// 漏写前缀导入的哈希值不能被当作明文密码
+	rawHash := "4818954780c108afebf0e7faffe9212dbee27a6ab95dd687b9c9815446e96e12"
+	ok, _, err := NewHasher().Verify(rawHash, rawHash)
+	if ok || !errors.Is(err, ErrUnsupportedHash) {
+		t.Fatalf("Verify(unprefixed value) = %v, %v, want false, ErrUnsupportedHash", ok, err)
+	}
+
+	h := NewHasher()
+	h.LegacyVerifiers = append(DefaultVerifiers(), Plaintext)
+	ok, rehash, err := h.Verify("hunter2", "hunter2")
+	if err != nil || !ok || !rehash {
+		t.Fatalf("Verify(plaintext opted in) = %v, %v, %v, want true, true, nil", ok, rehash, err)
+	}
+}
@@ -0,0 +1,115 @@
+package passwords
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 验证密码哈希时返回的错误
+var (
+	ErrMalformedHash   = errors.New("malformed password hash")   // 密码哈希的格式错误
+	ErrUnsupportedHash = errors.New("unsupported password hash") // 没有支持该格式的验证器
+)
+
+// 密码哈希的前缀和编码格式的版本
+// 完整格式为 $pbkdf2-sha256$v=1$i=迭代次数$盐$哈希值，盐和哈希值使用不带填充的Base64编码
+const (
+	pbkdf2Prefix  = "$pbkdf2-sha256$"
+	pbkdf2Version = 1
+)
+
+// Hasher 使用PBKDF2-HMAC-SHA256计算加盐的密码哈希
+// 修改参数后，已有的哈希值仍可验证，并会在验证时提示需要重新计算
+// 其他系统导入的旧版哈希由LegacyVerifiers验证，验证成功后同样提示需要重新计算
+
+type Hasher struct {
+	Iterations int // 迭代次数
+	SaltLength int // 盐的长度（字节）
+	KeyLength  int // 哈希值的长度（字节）
+
+	// LegacyVerifiers 支持的旧版格式，按顺序使用第一个匹配的验证器
+	LegacyVerifiers []Verifier
+}
+
+// 哈希参数的默认值
+const (
+	DefaultIterations = 600000
+	DefaultSaltLength = 16
+	DefaultKeyLength  = 32
+)
+
+// NewHasher 创建一个使用默认参数的Hasher
+func NewHasher() *Hasher {
+	return &Hasher{
+		Iterations: DefaultIterations,
+		SaltLength: DefaultSaltLength,
+		KeyLength:  DefaultKeyLength,
+
+		LegacyVerifiers: DefaultVerifiers(),
+	}
+}
+
+// Hash 计算密码的哈希值，返回自描述的编码（包含版本、参数和盐）
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, h.Iterations, h.KeyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%sv=%d$i=%d$%s$%s", pbkdf2Prefix, pbkdf2Version, h.Iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify 验证密码是否与哈希值匹配，使用恒定时间比较
+// rehash为true表示密码正确但哈希值是旧版格式或不是以当前参数计算的，应使用Hash重新计算并保存
+func (h *Hasher) Verify(encoded, password string) (ok, rehash bool, err error) {
+	if !strings.HasPrefix(encoded, pbkdf2Prefix) {
+		for _, verifier := range h.LegacyVerifiers {
+			if verifier.Match(encoded) {
+				ok, err = verifier.Verify(encoded, password)
+				return ok, ok, err
+			}
+		}
+		return false, false, ErrUnsupportedHash
+	}
+
+	fields := strings.Split(strings.TrimPrefix(encoded, pbkdf2Prefix), "$")
+	if len(fields) != 4 || fields[0] != "v="+strconv.Itoa(pbkdf2Version) || !strings.HasPrefix(fields[1], "i=") {
+		return false, false, ErrMalformedHash
+	}
+	iterations, err := strconv.Atoi(strings.TrimPrefix(fields[1], "i="))
+	if err != nil || iterations <= 0 {
+		return false, false, ErrMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return false, false, ErrMalformedHash
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(fields[3])
+	if err != nil || len(expected) == 0 {
+		return false, false, ErrMalformedHash
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return false, false, err
+	}
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return false, false, nil
+	}
+
+	rehash = iterations != h.Iterations || len(salt) != h.SaltLength || len(expected) != h.KeyLength
+	return true, rehash, nil
+}
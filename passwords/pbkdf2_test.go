@@ -0,0 +1,27 @@
+package passwords
+
+import "testing"
+
+func TestPBKDF2KnownAnswer(t *testing.T) {
+	const encoded = "$pbkdf2-sha256$v=1$i=1000$MDEyMzQ1Njc4OWFiY2RlZg$pj4T35D2v4tYmC1sTJ1y5tcMADOdtnQGvuHmyYDQh2g"
+	ok, rehash, err := NewHasher().Verify(encoded, "hunter2")
+	if err != nil || !ok || !rehash {
+		t.Fatalf("Verify = %v, %v, %v, want true, true, nil", ok, rehash, err)
+	}
+	if ok, _, _ := NewHasher().Verify(encoded, "hunter3"); ok {
+		t.Fatal("Verify accepted a wrong password")
+	}
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	h := NewHasher()
+	h.Iterations = 1000
+	encoded, err := h.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, rehash, err := h.Verify(encoded, "hunter2")
+	if err != nil || !ok || rehash {
+		t.Fatalf("Verify = %v, %v, %v, want true, false, nil", ok, rehash, err)
+	}
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter 按键（如用户名、客户端地址）限制请求频率
+// 使用令牌桶算法：每个键最多连续请求Limit次，每隔Window/Limit恢复一次
+// 次数耗尽后锁定Lockout时长，锁定期间的请求全部被拒绝
+
+type Limiter struct {
+	Limit   int           // 时间窗口内允许的请求次数
+	Window  time.Duration // 时间窗口
+	Lockout time.Duration // 次数耗尽后的锁定时长，为0时只等待次数恢复
+
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // 测试时替换当前时间
+	mu        sync.Mutex
+}
+
+// bucket 表示一个键的令牌桶
+
+type bucket struct {
+	tokens      float64   // 剩余次数
+	updatedAt   time.Time // 上次更新剩余次数的时间
+	lockedUntil time.Time // 锁定的截止时间
+}
+
+// NewLimiter 创建一个限制器
+func NewLimiter(limit int, window, lockout time.Duration) *Limiter {
+	return &Limiter{
+		Limit:   limit,
+		Window:  window,
+		Lockout: lockout,
+	}
+}
+
+// Allow 记录一次请求，返回该请求是否被允许
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucket)
+	}
+	l.sweep(now)
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(l.Limit), updatedAt: now}
+		l.buckets[key] = b
+	}
+	if now.Before(b.lockedUntil) {
+		return false
+	}
+
+	l.refill(b, now)
+	if b.tokens < 1 {
+		b.lockedUntil = now.Add(l.Lockout)
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Refund 退还一次被Allow记录的请求，用于不应计入限制的请求（如参数错误）
+// 已被锁定的键仍保持锁定
+func (l *Limiter) Refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, exists := l.buckets[key]
+	if !exists {
+		return
+	}
+	l.refill(b, l.clock())
+	b.tokens = min(b.tokens+1, float64(l.Limit))
+}
+
+// Reset 清除键的全部记录并解除锁定，用于请求成功后（如登录成功）不再计入此前的请求
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.buckets, key)
+}
+
+// clock 返回当前时间
+func (l *Limiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// refill 根据经过的时间恢复次数
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	if l.Window > 0 {
+		b.tokens += float64(l.Limit) * float64(now.Sub(b.updatedAt)) / float64(l.Window)
+	}
+	if b.tokens > float64(l.Limit) {
+		b.tokens = float64(l.Limit)
+	}
+	b.updatedAt = now
+}
+
+// sweep 每隔一个时间窗口清理已恢复满且未锁定的键，避免占用的内存无限增长
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.Window {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Before(b.lockedUntil) {
+			continue
+		}
+		l.refill(b, now)
+		if b.tokens >= float64(l.Limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
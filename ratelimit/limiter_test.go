@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestLimiter 创建使用fakeClock的限制器
+func newTestLimiter(limit int, window, lockout time.Duration) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := NewLimiter(limit, window, lockout)
+	l.now = clock.Now
+	return l, clock
+}
+
+// allowN 连续请求n次，返回被允许的次数
+func allowN(l *Limiter, key string, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if l.Allow(key) {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l, clock := newTestLimiter(4, time.Minute, 0)
+	if n := allowN(l, "a", 10); n != 4 {
+		t.Fatalf("allowed %d of 10 requests, want 4", n)
+	}
+
+	// 每隔Window/Limit恢复一次
+	clock.Advance(15 * time.Second)
+	if n := allowN(l, "a", 10); n != 1 {
+		t.Fatalf("allowed %d after Window/Limit, want 1", n)
+	}
+	clock.Advance(29 * time.Second)
+	if n := allowN(l, "a", 10); n != 1 {
+		t.Fatalf("allowed %d after 29s, want 1", n)
+	}
+
+	// 最多恢复到Limit次
+	clock.Advance(time.Hour)
+	if n := allowN(l, "a", 10); n != 4 {
+		t.Fatalf("allowed %d after a long pause, want 4", n)
+	}
+
+	// 各个键的次数互不影响
+	if n := allowN(l, "b", 10); n != 4 {
+		t.Fatalf("allowed %d for another key, want 4", n)
+	}
+}
+
+func TestLimiterLockout(t *testing.T) {
+	l, clock := newTestLimiter(2, time.Minute, 5*time.Minute)
+	if n := allowN(l, "a", 3); n != 2 {
+		t.Fatalf("allowed %d of 3 requests, want 2", n)
+	}
+
+	// 锁定期间次数恢复了也不允许，被拒绝的请求不会延长锁定
+	clock.Advance(4 * time.Minute)
+	if l.Allow("a") {
+		t.Fatal("request allowed while locked")
+	}
+	clock.Advance(time.Minute)
+	if n := allowN(l, "a", 3); n != 2 {
+		t.Fatalf("allowed %d after the lockout, want 2", n)
+	}
+}
+
+func TestLimiterWithoutLockout(t *testing.T) {
+	l, clock := newTestLimiter(2, time.Minute, 0)
+	allowN(l, "a", 10)
+
+	// 只等待次数恢复
+	clock.Advance(30 * time.Second)
+	if !l.Allow("a") {
+		t.Fatal("request not allowed after Window/Limit")
+	}
+	if l.Allow("a") {
+		t.Fatal("request allowed before the next refill")
+	}
+}
+
+func TestLimiterRefund(t *testing.T) {
+	l, _ := newTestLimiter(2, time.Minute, 5*time.Minute)
+	for i := 0; i < 5; i++ {
+		if !l.Allow("a") {
+			t.Fatalf("request %d not allowed", i)
+		}
+		l.Refund("a")
+	}
+
+	// 退还不会超过Limit次
+	l.Refund("a")
+	if n := allowN(l, "a", 3); n != 2 {
+		t.Fatalf("allowed %d of 3 requests, want 2", n)
+	}
+
+	// 已锁定的键退还后仍保持锁定
+	l.Refund("a")
+	if l.Allow("a") {
+		t.Fatal("request allowed while locked")
+	}
+
+	// 不存在的键无需处理
+	l.Refund("missing")
+	if n := len(l.buckets); n != 1 {
+		t.Fatalf("%d buckets after refunding a missing key, want 1", n)
+	}
+}
+
+func TestLimiterReset(t *testing.T) {
+	l, _ := newTestLimiter(2, time.Minute, 5*time.Minute)
+	allowN(l, "a", 3)
+	l.Reset("a")
+	if n := allowN(l, "a", 3); n != 2 {
+		t.Fatalf("allowed %d after Reset, want 2", n)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, clock := newTestLimiter(2, time.Minute, 5*time.Minute)
+	l.Allow("idle")
+	allowN(l, "locked", 3)
+	clock.Advance(time.Minute)
+
+	// 已恢复满且未锁定的键被清理，锁定中的键保留
+	l.Allow("active")
+	if _, exists := l.buckets["idle"]; exists {
+		t.Error("idle key not swept")
+	}
+	if _, exists := l.buckets["locked"]; !exists {
+		t.Error("locked key swept")
+	}
+	if _, exists := l.buckets["active"]; !exists {
+		t.Error("active key swept")
+	}
+
+	// 每个时间窗口最多清理一次
+	l.Allow("recent")
+	clock.Advance(59 * time.Second)
+	l.Allow("other")
+	if _, exists := l.buckets["recent"]; !exists {
+		t.Error("swept again within the window")
+	}
+
+	// 锁定结束并恢复满后被清理
+	clock.Advance(5 * time.Minute)
+	l.Allow("other")
+	if _, exists := l.buckets["locked"]; exists {
+		t.Error("key not swept after the lockout")
+	}
+}
@@ -1,14 +1,39 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/CycleZero/mc-yggdrasil-go/models"
+	"github.com/CycleZero/mc-yggdrasil-go/ratelimit"
 	"github.com/CycleZero/mc-yggdrasil-go/service"
+	"github.com/CycleZero/mc-yggdrasil-go/signing"
+	"github.com/CycleZero/mc-yggdrasil-go/textures"
+)
+
+// 服务端实现的名称和版本，作为API元数据的默认值
+const (
+	ImplementationName    = "mc-yggdrasil-go"
+	ImplementationVersion = "0.1.0"
+)
+
+// 认证和登出请求频率限制的默认值
+const (
+	DefaultUsernameLimit = 5               // 每个用户名每分钟密码错误的次数
+	DefaultIPLimit       = 20              // 每个客户端地址每分钟的请求次数
+	DefaultLoginLockout  = 5 * time.Minute // 用户名密码错误次数超出限制后的锁定时长，客户端地址默认只限速不锁定
 )
 
 // YggdrasilServer 表示Yggdrasil认证服务器
@@ -16,7 +41,55 @@ import (
 type YggdrasilServer struct {
 	Port    int
 	Service service.YggdrasilService
-	server  *http.Server
+
+	// API元数据，在根路径返回给启动器
+	Meta               models.MetadataMeta // 服务端的元数据
+	SkinDomains        []string            // 材质域名白名单
+	SignaturePublicKey string              // 用于验证数字签名的公钥（PEM格式），为空时使用Signer的公钥
+
+	// Signer 对角色属性进行数字签名的签名器，应与服务层使用同一个，为nil时使用服务层的签名器
+	Signer *signing.Signer
+
+	// TextureStore 提供材质文件的存储，应与服务层使用同一个，为nil时使用服务层的材质存储
+	TextureStore textures.Store
+
+	// APILocation API地址，非空时通过X-Authlib-Injector-API-Location头返回（可以是相对URL）
+	APILocation string
+
+	// 限制认证和登出请求的频率，防止暴力破解密码，为nil时不限制
+	// 被限制的请求与密码错误时的响应相同
+	// 同一地址后面可能有许多玩家（如反向代理或NAT），IPLimiter默认只限速不锁定
+	UsernameLimiter *ratelimit.Limiter // 按用户名限制，只计入密码错误的请求，登录成功后清除
+	IPLimiter       *ratelimit.Limiter // 按客户端地址限制
+
+	// LimitLocalClients 为true时，直接连接的本机或内网地址同样按客户端地址限制
+	// 默认为false：这些连接可能来自未配置为TrustedProxies的反向代理，限制代理的地址会使所有玩家共用同一个限制
+	// 玩家在局域网内直接连接服务器时可以启用
+	LimitLocalClients bool
+
+	// Prefix 路由的路径前缀，如 /api/yggdrasil，为空时挂载在根路径
+	// 修改后应同时将服务层的TextureBaseURL设为包含该前缀的地址
+	Prefix string
+
+	// TLSConfig 非nil时使用HTTPS提供服务，证书通过Certificates或GetCertificate配置
+	TLSConfig *tls.Config
+
+	// TrustedProxies 受信任的反向代理地址段，仅当请求直接来自这些地址时，才从X-Forwarded-For或X-Real-IP头中获取客户端地址
+	TrustedProxies []netip.Prefix
+
+	// TrustUnixSocketPeers 为true时，通过Unix套接字连接的请求视为来自受信任的代理
+	// 默认为false，此时这些请求的客户端地址为空：Unix套接字没有对端地址，而能连接套接字的本机进程不一定是反向代理
+	TrustUnixSocketPeers bool
+
+	server *http.Server
+	cancel context.CancelFunc // 取消所有请求的上下文
+}
+
+// resourceProvider 由StoreYggdrasilService实现，服务器未单独配置签名器或材质存储时使用服务层的配置
+
+type resourceProvider interface {
+	GetSigner() *signing.Signer
+	GetTextureStore() textures.Store
 }
 
 // NewYggdrasilServer 创建一个新的Yggdrasil服务器
@@ -24,40 +97,130 @@ func NewYggdrasilServer(port int, service service.YggdrasilService) *YggdrasilSe
 	return &YggdrasilServer{
 		Port:    port,
 		Service: service,
+		Meta: models.MetadataMeta{
+			ImplementationName:    ImplementationName,
+			ImplementationVersion: ImplementationVersion,
+		},
+		UsernameLimiter: ratelimit.NewLimiter(DefaultUsernameLimit, time.Minute, DefaultLoginLockout),
+		IPLimiter:       ratelimit.NewLimiter(DefaultIPLimit, time.Minute, 0),
 	}
 }
 
-// Start 启动Yggdrasil服务器
-func (s *YggdrasilServer) Start() error {
+// Handler 返回处理全部Yggdrasil请求的处理器，所有路由都位于Prefix之下
+// 可以挂载到已有的Web应用中，此时请求的上下文不会在Stop时被取消
+func (s *YggdrasilServer) Handler() http.Handler {
+	prefix := s.prefix()
+
 	// 注册路由
 	r := http.NewServeMux()
-	r.HandleFunc("/authserver/authenticate", s.handleAuthenticate)
-	r.HandleFunc("/authserver/refresh", s.handleRefresh)
-	r.HandleFunc("/authserver/validate", s.handleValidate)
-	r.HandleFunc("/authserver/invalidate", s.handleInvalidate)
-	r.HandleFunc("/authserver/signout", s.handleSignout)
-	r.HandleFunc("/", s.handleRoot)
-
-	// 创建HTTP服务器
+	r.HandleFunc(prefix+"/authserver/authenticate", s.handleAuthenticate)
+	r.HandleFunc(prefix+"/authserver/refresh", s.handleRefresh)
+	r.HandleFunc(prefix+"/authserver/validate", s.handleValidate)
+	r.HandleFunc(prefix+"/authserver/invalidate", s.handleInvalidate)
+	r.HandleFunc(prefix+"/authserver/signout", s.handleSignout)
+	r.HandleFunc(prefix+"/sessionserver/session/minecraft/join", s.handleJoin)
+	r.HandleFunc(prefix+"/sessionserver/session/minecraft/hasJoined", s.handleHasJoined)
+	r.HandleFunc(prefix+"/sessionserver/session/minecraft/profile/{uuid}", s.handleProfile)
+	r.HandleFunc(prefix+"/api/profiles/minecraft", s.handleProfilesByNames)
+	r.HandleFunc(prefix+"/api/user/profile/{uuid}/{textureType}", s.handleTexture)
+	r.HandleFunc(prefix+"/textures/{hash}", s.handleTextureFile)
+	r.HandleFunc(prefix+"/{$}", s.handleRoot)
+	if prefix != "" {
+		// API地址不以/结尾时同样返回API元数据
+		r.HandleFunc(prefix, s.handleRoot)
+	}
+
+	return s.APILocationMiddleware(s.contextMiddleware(r))
+}
+
+// prefix 返回规范化的路径前缀：以/开头且不以/结尾，未配置时为空
+func (s *YggdrasilServer) prefix() string {
+	prefix := strings.TrimSuffix(s.Prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// signer 返回签名器，未单独配置时使用服务层的签名器
+func (s *YggdrasilServer) signer() *signing.Signer {
+	if s.Signer != nil {
+		return s.Signer
+	}
+	if provider, ok := s.Service.(resourceProvider); ok {
+		return provider.GetSigner()
+	}
+	return nil
+}
+
+// textureStore 返回材质存储，未单独配置时使用服务层的材质存储
+func (s *YggdrasilServer) textureStore() textures.Store {
+	if s.TextureStore != nil {
+		return s.TextureStore
+	}
+	if provider, ok := s.Service.(resourceProvider); ok {
+		return provider.GetTextureStore()
+	}
+	return nil
+}
+
+// Serve 在指定的监听器上接受连接并处理请求，监听器可以是TCP或Unix套接字
+// TLSConfig不为nil时使用HTTPS，证书需配置在TLSConfig中
+func (s *YggdrasilServer) Serve(l net.Listener) error {
+	// 创建HTTP服务器，所有请求的上下文都派生自baseCtx，以便停止服务器时取消
+	baseCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
 	s.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.Port),
-		Handler: r,
+		Handler:   s.Handler(),
+		TLSConfig: s.TLSConfig,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 
-	// 启动服务器
-	log.Printf("Yggdrasil服务器启动在端口 %d...\n", s.Port)
-	return s.server.ListenAndServe()
+	log.Printf("Yggdrasil服务器启动在 %s...\n", l.Addr())
+	if s.IPLimiter != nil && !s.LimitLocalClients {
+		log.Println("来自本机或内网地址的直接连接不按客户端地址限制登录频率，可通过LimitLocalClients启用")
+	}
+	if s.TLSConfig != nil {
+		return s.server.ServeTLS(l, "", "")
+	}
+	return s.server.Serve(l)
+}
+
+// Start 启动Yggdrasil服务器，监听Port指定的TCP端口
+func (s *YggdrasilServer) Start() error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
 }
 
 // Stop 停止Yggdrasil服务器（使用优雅关闭）
 func (s *YggdrasilServer) Stop(ctx context.Context) error {
 	if s.server != nil {
 		log.Println("正在停止Yggdrasil服务器...")
+		// 超过关闭时限后，取消仍在处理中的请求
+		stop := context.AfterFunc(ctx, s.cancel)
+		defer stop()
 		return s.server.Shutdown(ctx)
 	}
 	return nil
 }
 
+// APILocationMiddleware 为所有响应添加X-Authlib-Injector-API-Location头
+// 可用于包装网站首页等其他处理器，使用户可以直接在启动器中填写网站地址
+// https://github.com/yushijinhun/authlib-injector/wiki/%E5%90%AF%E5%8A%A8%E5%99%A8%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#api-%E5%9C%B0%E5%9D%80%E6%8C%87%E7%A4%BAali
+func (s *YggdrasilServer) APILocationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.APILocation != "" {
+			w.Header().Set("X-Authlib-Injector-API-Location", s.APILocation)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // handleAuthenticate 处理认证请求
 // POST /authserver/authenticate
 // https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E8%AE%A4%E8%AF%81
@@ -75,10 +238,17 @@ func (s *YggdrasilServer) handleAuthenticate(w http.ResponseWriter, r *http.Requ
 	}
 	defer r.Body.Close()
 
+	// 限制请求频率，被限制时与密码错误的响应相同，不向攻击者透露任何信息
+	if !s.allowLogin(r, req.Username) {
+		s.writeServiceError(w, service.ErrInvalidCredentials)
+		return
+	}
+
 	// 调用服务处理认证
-	resp, err := s.Service.Auth(req)
+	resp, err := s.Service.Auth(r.Context(), req)
+	s.finishLogin(req.Username, err)
 	if err != nil {
-		s.writeErrorResponse(w, http.StatusForbidden, "ForbiddenOperationException", err.Error())
+		s.writeServiceError(w, err)
 		return
 	}
 
@@ -104,9 +274,9 @@ func (s *YggdrasilServer) handleRefresh(w http.ResponseWriter, r *http.Request)
 	defer r.Body.Close()
 
 	// 调用服务处理刷新
-	resp, err := s.Service.Refresh(req)
+	resp, err := s.Service.Refresh(r.Context(), req)
 	if err != nil {
-		s.writeErrorResponse(w, http.StatusForbidden, "ForbiddenOperationException", err.Error())
+		s.writeServiceError(w, err)
 		return
 	}
 
@@ -132,9 +302,9 @@ func (s *YggdrasilServer) handleValidate(w http.ResponseWriter, r *http.Request)
 	defer r.Body.Close()
 
 	// 调用服务处理验证
-	valid, err := s.Service.Validate(req)
+	valid, err := s.Service.Validate(r.Context(), req)
 	if err != nil {
-		s.writeErrorResponse(w, http.StatusForbidden, "ForbiddenOperationException", err.Error())
+		s.writeServiceError(w, err)
 		return
 	}
 
@@ -142,7 +312,7 @@ func (s *YggdrasilServer) handleValidate(w http.ResponseWriter, r *http.Request)
 	if valid {
 		w.WriteHeader(http.StatusNoContent)
 	} else {
-		s.writeErrorResponse(w, http.StatusForbidden, "ForbiddenOperationException", "Invalid token.")
+		s.writeServiceError(w, service.ErrInvalidToken)
 	}
 }
 
@@ -164,9 +334,9 @@ func (s *YggdrasilServer) handleInvalidate(w http.ResponseWriter, r *http.Reques
 	defer r.Body.Close()
 
 	// 调用服务处理失效
-	err := s.Service.Invalidate(req)
+	err := s.Service.Invalidate(r.Context(), req)
 	if err != nil {
-		s.writeErrorResponse(w, http.StatusForbidden, "ForbiddenOperationException", err.Error())
+		s.writeServiceError(w, err)
 		return
 	}
 
@@ -191,10 +361,216 @@ func (s *YggdrasilServer) handleSignout(w http.ResponseWriter, r *http.Request)
 	}
 	defer r.Body.Close()
 
+	// 限制请求频率，被限制时与密码错误的响应相同，不向攻击者透露任何信息
+	if !s.allowLogin(r, req.Username) {
+		s.writeServiceError(w, service.ErrInvalidCredentials)
+		return
+	}
+
 	// 调用服务处理登出
-	err := s.Service.Signout(req)
+	err := s.Service.Signout(r.Context(), req)
+	s.finishLogin(req.Username, err)
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	// 根据技术规范，成功返回204 No Content
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleJoin 处理客户端进入服务器请求
+// POST /sessionserver/session/minecraft/join
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E5%AE%A2%E6%88%B7%E7%AB%AF%E8%BF%9B%E5%85%A5%E6%9C%8D%E5%8A%A1%E5%99%A8
+func (s *YggdrasilServer) handleJoin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 解析请求体
+	var req models.JoinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "IllegalArgumentException", err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	// 调用服务记录进入服务器
+	err := s.Service.Join(r.Context(), req)
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	// 根据技术规范，成功返回204 No Content
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHasJoined 处理服务端验证客户端请求
+// GET /sessionserver/session/minecraft/hasJoined?username={username}&serverId={serverId}&ip={ip}
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E6%9C%8D%E5%8A%A1%E7%AB%AF%E9%AA%8C%E8%AF%81%E5%AE%A2%E6%88%B7%E7%AB%AF
+func (s *YggdrasilServer) handleHasJoined(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 解析查询参数
+	query := r.URL.Query()
+	username := query.Get("username")
+	serverID := query.Get("serverId")
+	if username == "" || serverID == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "IllegalArgumentException", "username and serverId are required.")
+		return
+	}
+
+	// 调用服务验证客户端
+	profile, err := s.Service.HasJoined(r.Context(), username, serverID, query.Get("ip"))
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	// 根据技术规范，验证失败返回204 No Content
+	if profile == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, profile)
+}
+
+// handleProfile 处理查询角色属性请求
+// GET /sessionserver/session/minecraft/profile/{uuid}?unsigned={unsigned}
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E6%9F%A5%E8%AF%A2%E8%A7%92%E8%89%B2%E5%B1%9E%E6%80%A7
+func (s *YggdrasilServer) handleProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 根据技术规范，unsigned默认为true，仅当其为false时才包含签名
+	unsigned := r.URL.Query().Get("unsigned") != "false"
+
+	// 调用服务查询角色
+	profile, err := s.Service.GetProfile(r.Context(), r.PathValue("uuid"), unsigned)
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	// 根据技术规范，角色不存在时返回204 No Content
+	if profile == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, profile)
+}
+
+// handleProfilesByNames 处理按名称批量查询角色请求
+// POST /api/profiles/minecraft
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E6%8C%89%E5%90%8D%E7%A7%B0%E6%89%B9%E9%87%8F%E6%9F%A5%E8%AF%A2%E8%A7%92%E8%89%B2
+func (s *YggdrasilServer) handleProfilesByNames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 解析请求体
+	var names []string
+	if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "IllegalArgumentException", err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	// 调用服务查询角色
+	profiles, err := s.Service.GetProfilesByNames(r.Context(), names)
+	if err != nil {
+		s.writeServiceError(w, err)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, profiles)
+}
+
+// maxTextureUploadSize 上传材质请求体的最大长度（为multipart表单的其他部分预留空间）
+const maxTextureUploadSize = textures.MaxFileSize + 64<<10
+
+// handleTexture 处理材质上传和删除请求
+// PUT /api/user/profile/{uuid}/{textureType}
+// DELETE /api/user/profile/{uuid}/{textureType}
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E6%9D%90%E8%B4%A8%E4%B8%8A%E4%BC%A0
+func (s *YggdrasilServer) handleTexture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 根据技术规范，需要通过Bearer令牌认证
+	accessToken, ok := bearerToken(r)
+	if !ok {
+		s.writeServiceError(w, service.ErrUnauthorized)
+		return
+	}
+
+	// 路径中的材质类型为小写的skin或cape
+	textureType := models.TextureType(strings.ToUpper(r.PathValue("textureType")))
+	if textureType != models.TextureSkin && textureType != models.TextureCape {
+		s.writeErrorResponse(w, http.StatusBadRequest, "IllegalArgumentException", "Unknown texture type.")
+		return
+	}
+	profileID := r.PathValue("uuid")
+
+	if r.Method == http.MethodDelete {
+		// 调用服务删除材质
+		if err := s.Service.DeleteTexture(r.Context(), accessToken, profileID, textureType); err != nil {
+			s.writeServiceError(w, textureError(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// 解析multipart表单
+	r.Body = http.MaxBytesReader(w, r.Body, maxTextureUploadSize)
+	if err := r.ParseMultipartForm(maxTextureUploadSize); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "IllegalArgumentException", err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	// 仅皮肤有材质模型，空字符串表示默认模型
+	model := models.TextureModelDefault
+	if textureType == models.TextureSkin {
+		switch r.FormValue("model") {
+		case "":
+		case string(models.TextureModelSlim):
+			model = models.TextureModelSlim
+		default:
+			s.writeErrorResponse(w, http.StatusBadRequest, "IllegalArgumentException", "Unknown texture model.")
+			return
+		}
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "IllegalArgumentException", err.Error())
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
 	if err != nil {
-		s.writeErrorResponse(w, http.StatusForbidden, "ForbiddenOperationException", err.Error())
+		s.writeErrorResponse(w, http.StatusBadRequest, "IllegalArgumentException", err.Error())
+		return
+	}
+
+	// 调用服务上传材质
+	if err := s.Service.UploadTexture(r.Context(), accessToken, profileID, textureType, model, data); err != nil {
+		s.writeServiceError(w, textureError(err))
 		return
 	}
 
@@ -202,15 +578,214 @@ func (s *YggdrasilServer) handleSignout(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// handleRoot 处理根路径请求
+// handleTextureFile 处理材质文件请求
+// GET /textures/{hash}
+// 材质以哈希值寻址，内容不会改变，因此可以长期缓存
+func (s *YggdrasilServer) handleTextureFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	textureStore := s.textureStore()
+	if textureStore == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	hash := r.PathValue("hash")
+	data, err := textureStore.Get(hash)
+	if errors.Is(err, textures.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("读取材质失败: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// handleRoot 处理API元数据获取请求
+// GET /
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#api%E5%85%83%E6%95%B0%E6%8D%AE%E8%8E%B7%E5%8F%96
 func (s *YggdrasilServer) handleRoot(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "ok",
-		"message": "Yggdrasil认证服务器正在运行",
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 未配置时材质由本服务器提供，白名单为当前请求的域名；根据技术规范，skinDomains必须是数组
+	skinDomains := s.SkinDomains
+	if skinDomains == nil {
+		skinDomains = []string{}
+		if host := (&url.URL{Host: r.Host}).Hostname(); host != "" {
+			skinDomains = append(skinDomains, host)
+		}
+	}
+
+	// 未显式配置公钥时，使用签名器的公钥
+	publicKey := s.SignaturePublicKey
+	if signer := s.signer(); publicKey == "" && signer != nil {
+		var err error
+		if publicKey, err = signer.PublicKeyPEM(); err != nil {
+			s.writeServiceError(w, err)
+			return
+		}
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, models.APIMetadata{
+		Meta:               s.Meta,
+		SkinDomains:        skinDomains,
+		SignaturePublicKey: publicKey,
+	})
+}
+
+// allowLogin 检查认证或登出请求是否超出频率限制
+// 用户名的计数在请求完成后由finishLogin根据结果修正
+func (s *YggdrasilServer) allowLogin(r *http.Request, username string) bool {
+	ip := service.ClientIPFromContext(r.Context())
+	if s.IPLimiter != nil && s.limitByIP(r, ip) && !s.IPLimiter.Allow(ip) {
+		log.Printf("客户端 %s 的登录请求过于频繁\n", ip)
+		return false
+	}
+	if s.UsernameLimiter != nil && !s.UsernameLimiter.Allow(strings.ToLower(username)) {
+		log.Printf("用户 %s 的登录请求过于频繁（客户端 %s）\n", username, ip)
+		return false
+	}
+	return true
+}
+
+// finishLogin 根据认证或登出的结果修正用户名的计数，只有密码错误的请求计入限制
+// 登录成功时清除此前的记录，玩家不会因为正常的登录（如同时使用多个启动器）被锁定；其他错误（如参数错误）不计入
+func (s *YggdrasilServer) finishLogin(username string, err error) {
+	if s.UsernameLimiter == nil || errors.Is(err, service.ErrInvalidCredentials) {
+		return
+	}
+	if err == nil {
+		s.UsernameLimiter.Reset(strings.ToLower(username))
+	} else {
+		s.UsernameLimiter.Refund(strings.ToLower(username))
+	}
+}
+
+// limitByIP 检查是否按客户端地址限制请求频率
+// 客户端地址经受信任的代理解析时总是限制；直接连接时只限制公网地址，除非启用了LimitLocalClients
+// 地址无法确定（如通过Unix套接字连接但没有转发头）时不限制，避免所有客户端共用同一个限制
+func (s *YggdrasilServer) limitByIP(r *http.Request, ip string) bool {
+	client, err := netip.ParseAddr(ip)
+	if err != nil || s.trustedProxy(client) {
+		return false
+	}
+	if _, trusted := s.peer(r); trusted || s.LimitLocalClients {
+		return true
+	}
+	return client.IsGlobalUnicast() && !client.IsPrivate()
+}
+
+// textureError 根据技术规范，材质接口的令牌无效时返回401 Unauthorized
+func textureError(err error) error {
+	if errors.Is(err, service.ErrInvalidToken) {
+		return service.ErrUnauthorized
+	}
+	return err
+}
+
+// bearerToken 从Authorization请求头中提取Bearer令牌
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return auth[len(prefix):], true
+}
+
+// contextMiddleware 解析请求的客户端地址和API地址，并通过上下文传递给服务层
+func (s *YggdrasilServer) contextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := service.WithClientIP(r.Context(), s.clientIP(r))
+		ctx = service.WithBaseURL(ctx, s.baseURL(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// baseURL 返回请求的API地址（不以/结尾），用作材质URL的默认基础
+// 请求来自受信任的代理时，根据X-Forwarded-Proto头判断客户端使用的协议
+func (s *YggdrasilServer) baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	} else if _, trusted := s.peer(r); trusted && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + s.prefix()
+}
+
+// clientIP 返回请求的客户端地址，无法确定时返回空字符串
+// 请求来自受信任的代理时，从右向左跳过X-Forwarded-For中受信任的代理，第一个不受信任的地址即为客户端地址
+// 其余地址可能由客户端伪造，不予采用
+func (s *YggdrasilServer) clientIP(r *http.Request) string {
+	peer, trusted := s.peer(r)
+	if !trusted {
+		if !peer.IsValid() {
+			return ""
+		}
+		return peer.String()
+	}
+
+	client := peer
+	if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = addr.Unmap()
+			if !s.trustedProxy(client) {
+				break
+			}
+		}
+	} else if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		client = addr.Unmap()
+	}
+	if !client.IsValid() {
+		return ""
+	}
+	return client.String()
+}
+
+// peer 返回直接连接的对端地址及其是否为受信任的代理
+// 通过Unix套接字连接时对端地址无效，是否受信任由TrustUnixSocketPeers决定
+func (s *YggdrasilServer) peer(r *http.Request) (netip.Addr, bool) {
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && local.Network() == "unix" {
+		return netip.Addr{}, s.TrustUnixSocketPeers
+	}
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	peer := addrPort.Addr().Unmap()
+	return peer, s.trustedProxy(peer)
+}
+
+// trustedProxy 检查地址是否属于受信任的代理
+func (s *YggdrasilServer) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range s.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // writeJSONResponse 写入JSON响应
 func (s *YggdrasilServer) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -233,3 +808,13 @@ func (s *YggdrasilServer) writeErrorResponse(w http.ResponseWriter, statusCode i
 		log.Printf("写入错误响应失败: %v\n", err)
 	}
 }
+
+// writeServiceError 根据服务层返回的错误写入错误响应，未知的错误视为服务器内部错误
+func (s *YggdrasilServer) writeServiceError(w http.ResponseWriter, err error) {
+	var serviceErr *service.Error
+	if !errors.As(err, &serviceErr) {
+		log.Printf("处理请求失败: %v\n", err)
+		serviceErr = service.ErrInternal
+	}
+	s.writeJSONResponse(w, serviceErr.Status, serviceErr.Response())
+}
@@ -0,0 +1,370 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+	"github.com/CycleZero/mc-yggdrasil-go/service"
+	"github.com/CycleZero/mc-yggdrasil-go/signing"
+)
+
+// newTestServer 创建一个包含n个用户的服务器，用户名为user0@example.com等，密码均为password
+func newTestServer(t *testing.T, n int) *YggdrasilServer {
+	t.Helper()
+	svc := service.NewMemoryYggdrasilService()
+	svc.PasswordHasher.Iterations = 1
+	for i := 0; i < n; i++ {
+		if _, err := svc.AddUser(fmt.Sprintf("user%d@example.com", i), "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewYggdrasilServer(0, svc)
+}
+
+// authenticate 以第i个用户的身份发送认证请求，返回响应的状态码
+func authenticate(h http.Handler, i int, remoteAddr, forwardedFor string) int {
+	return login(h, fmt.Sprintf("user%d@example.com", i), "password", remoteAddr, forwardedFor)
+}
+
+// login 使用用户名和密码发送认证请求，返回响应的状态码
+func login(h http.Handler, username, password, remoteAddr, forwardedFor string) int {
+	body, _ := json.Marshal(models.AuthRequest{Username: username, Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/authserver/authenticate", bytes.NewReader(body))
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestIPLimiterSkipsUntrustedLocalPeer(t *testing.T) {
+	// 未配置受信任的代理时，本机的连接可能是反向代理转发的，所有玩家都不能因此被限制
+	h := newTestServer(t, 30).Handler()
+	for i := 0; i < 30; i++ {
+		if code := authenticate(h, i, "127.0.0.1:40000", ""); code != http.StatusOK {
+			t.Fatalf("login %d: status %d", i, code)
+		}
+	}
+}
+
+func TestIPLimiterLimitsLocalClients(t *testing.T) {
+	s := newTestServer(t, DefaultIPLimit+1)
+	s.LimitLocalClients = true
+	h := s.Handler()
+	for i := 0; i < DefaultIPLimit; i++ {
+		if code := authenticate(h, i, "192.168.1.20:40000", ""); code != http.StatusOK {
+			t.Fatalf("login %d: status %d", i, code)
+		}
+	}
+	if code := authenticate(h, DefaultIPLimit, "192.168.1.20:40000", ""); code != http.StatusForbidden {
+		t.Fatalf("login over the limit: status %d, want 403", code)
+	}
+}
+
+func TestIPLimiterThrottlesWithoutLockout(t *testing.T) {
+	s := newTestServer(t, DefaultIPLimit+1)
+	h := s.Handler()
+	for i := 0; i < DefaultIPLimit; i++ {
+		if code := authenticate(h, i, "203.0.113.7:40000", ""); code != http.StatusOK {
+			t.Fatalf("login %d: status %d", i, code)
+		}
+	}
+	if code := authenticate(h, DefaultIPLimit, "203.0.113.7:40000", ""); code != http.StatusForbidden {
+		t.Fatalf("login over the limit: status %d, want 403", code)
+	}
+	if s.IPLimiter.Lockout != 0 {
+		t.Fatalf("default IPLimiter.Lockout = %v, want 0", s.IPLimiter.Lockout)
+	}
+}
+
+func TestIPLimiterUsesForwardedAddress(t *testing.T) {
+	s := newTestServer(t, DefaultIPLimit+5)
+	s.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	h := s.Handler()
+	for i := 0; i < DefaultIPLimit+5; i++ {
+		forwardedFor := fmt.Sprintf("198.51.100.%d", i+1)
+		if code := authenticate(h, i, "10.0.0.1:40000", forwardedFor); code != http.StatusOK {
+			t.Fatalf("login %d from %s: status %d", i, forwardedFor, code)
+		}
+	}
+}
+
+func TestUsernameLimiterIgnoresSuccessfulLogins(t *testing.T) {
+	h := newTestServer(t, 1).Handler()
+	for i := 0; i < 3*DefaultUsernameLimit; i++ {
+		if code := login(h, "user0@example.com", "password", fmt.Sprintf("203.0.113.%d:40000", i+1), ""); code != http.StatusOK {
+			t.Fatalf("login %d: status %d", i, code)
+		}
+	}
+}
+
+func TestUsernameLimiterResetsAfterSuccess(t *testing.T) {
+	h := newTestServer(t, 1).Handler()
+	for round := 0; round < 3; round++ {
+		for i := 0; i < DefaultUsernameLimit-1; i++ {
+			if code := login(h, "user0@example.com", "wrong", "203.0.113.7:40000", ""); code != http.StatusForbidden {
+				t.Fatalf("wrong password: status %d", code)
+			}
+		}
+		if code := login(h, "user0@example.com", "password", "198.51.100.7:40000", ""); code != http.StatusOK {
+			t.Fatalf("round %d: login after %d failures: status %d", round, DefaultUsernameLimit-1, code)
+		}
+	}
+}
+
+func TestUsernameLimiterLocksAfterFailures(t *testing.T) {
+	h := newTestServer(t, 2).Handler()
+	for i := 0; i < DefaultUsernameLimit; i++ {
+		login(h, "USER0@example.com", "wrong", fmt.Sprintf("203.0.113.%d:40000", i+1), "")
+	}
+
+	// 锁定期间即使密码正确也被拒绝，其他用户不受影响
+	if code := login(h, "user0@example.com", "password", "198.51.100.7:40000", ""); code != http.StatusForbidden {
+		t.Fatalf("login while locked: status %d, want 403", code)
+	}
+	if code := login(h, "user1@example.com", "password", "198.51.100.7:40000", ""); code != http.StatusOK {
+		t.Fatalf("login of another user: status %d", code)
+	}
+}
+
+func TestUsernameLimiterIgnoresInvalidRequests(t *testing.T) {
+	h := newTestServer(t, 1).Handler()
+	body, _ := json.Marshal(models.AuthRequest{Username: "user0@example.com", Password: "wrong", ClientToken: strings.Repeat("x", service.MaxClientTokenLength+1)})
+	for i := 0; i < 2*DefaultUsernameLimit; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/authserver/authenticate", bytes.NewReader(body))
+		req.RemoteAddr = fmt.Sprintf("203.0.113.%d:40000", i+1)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("invalid request %d: status %d, want 400", i, rec.Code)
+		}
+	}
+	if code := login(h, "user0@example.com", "password", "198.51.100.7:40000", ""); code != http.StatusOK {
+		t.Fatalf("login after invalid requests: status %d", code)
+	}
+}
+
+// serveUnix 在Unix套接字上启动服务器，返回通过该套接字连接的客户端
+func serveUnix(t *testing.T, s *YggdrasilServer) *http.Client {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "yggdrasil.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Stop(context.Background()) })
+
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+}
+
+// postJSON 通过客户端发送JSON请求
+func postJSON(t *testing.T, c *http.Client, path, forwardedFor string, body any) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPost, "http://yggdrasil"+path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestUnixSocketUsesForwardedAddress(t *testing.T) {
+	const users = DefaultIPLimit + 5
+	s := newTestServer(t, users)
+	s.TrustUnixSocketPeers = true
+	c := serveUnix(t, s)
+
+	// 每个玩家的地址不同，不能因为共用Unix套接字而被限制
+	for i := 0; i < users; i++ {
+		req := models.AuthRequest{Username: fmt.Sprintf("user%d@example.com", i), Password: "password"}
+		if resp := postJSON(t, c, "/authserver/authenticate", fmt.Sprintf("198.51.100.%d", i+1), req); resp.StatusCode != http.StatusOK {
+			t.Fatalf("login %d: status %d", i, resp.StatusCode)
+		}
+	}
+}
+
+func TestUnixSocketJoinRecordsForwardedAddress(t *testing.T) {
+	svc := service.NewMemoryYggdrasilService()
+	svc.PasswordHasher.Iterations = 1
+	userID, err := svc.AddUser("player@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := svc.AddProfile(userID, "Player")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewYggdrasilServer(0, svc)
+	s.TrustUnixSocketPeers = true
+	c := serveUnix(t, s)
+
+	const clientIP = "198.51.100.9"
+	var auth models.AuthResponse
+	resp := postJSON(t, c, "/authserver/authenticate", clientIP, models.AuthRequest{Username: "player@example.com", Password: "password"})
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		t.Fatal(err)
+	}
+	join := models.JoinRequest{AccessToken: auth.AccessToken, SelectedProfile: profile.ID, ServerID: "server-hash"}
+	if resp := postJSON(t, c, "/sessionserver/session/minecraft/join", clientIP, join); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("join: status %d", resp.StatusCode)
+	}
+
+	query := url.Values{"username": {"Player"}, "serverId": {"server-hash"}, "ip": {clientIP}}
+	resp, err = c.Get("http://yggdrasil/sessionserver/session/minecraft/hasJoined?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("hasJoined with the forwarded address: status %d", resp.StatusCode)
+	}
+}
+
+func TestUnixSocketUntrustedByDefault(t *testing.T) {
+	s := newTestServer(t, 1)
+	clientIPs := make(chan string, 1)
+	s.Service = clientIPRecorder{s.Service, clientIPs}
+	c := serveUnix(t, s)
+
+	// 默认不信任Unix套接字的对端，忽略转发头
+	req := models.AuthRequest{Username: "user0@example.com", Password: "password"}
+	if resp := postJSON(t, c, "/authserver/authenticate", "198.51.100.9", req); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login: status %d", resp.StatusCode)
+	}
+	if clientIP := <-clientIPs; clientIP != "" {
+		t.Fatalf("client address = %q, want empty", clientIP)
+	}
+}
+
+// clientIPRecorder 记录认证请求上下文中的客户端地址
+
+type clientIPRecorder struct {
+	service.YggdrasilService
+	clientIPs chan<- string
+}
+
+func (r clientIPRecorder) Auth(ctx context.Context, req models.AuthRequest) (*models.AuthResponse, error) {
+	r.clientIPs <- service.ClientIPFromContext(ctx)
+	return r.YggdrasilService.Auth(ctx, req)
+}
+
+func TestDefaultsServeAdvertisedTextures(t *testing.T) {
+	svc := service.NewMemoryYggdrasilService()
+	svc.PasswordHasher.Iterations = 1
+	signer, err := signing.GenerateSigner(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Signer = signer
+	userID, err := svc.AddUser("player@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := svc.AddProfile(userID, "Player")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewYggdrasilServer(0, svc)
+	s.Prefix = "/api/yggdrasil"
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	base := ts.URL + "/api/yggdrasil"
+
+	// API元数据返回服务层签名器的公钥和当前域名
+	var meta models.APIMetadata
+	resp, err := http.Get(base + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&meta)
+	resp.Body.Close()
+	if meta.SignaturePublicKey == "" || len(meta.SkinDomains) != 1 || meta.SkinDomains[0] != "127.0.0.1" {
+		t.Fatalf("metadata = %+v", meta)
+	}
+
+	// 上传皮肤
+	var auth models.AuthResponse
+	body, _ := json.Marshal(models.AuthRequest{Username: "player@example.com", Password: "password"})
+	resp, err = http.Post(base+"/authserver/authenticate", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("file", "skin.png")
+	png.Encode(part, image.NewNRGBA(image.Rect(0, 0, 64, 64)))
+	mw.Close()
+	req, _ := http.NewRequest(http.MethodPut, base+"/api/user/profile/"+profile.ID+"/skin", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+auth.AccessToken)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("upload: status %d", resp.StatusCode)
+	}
+
+	// textures属性中的URL是绝对地址，且可以下载
+	var full models.Profile
+	resp, err = http.Get(base + "/sessionserver/session/minecraft/profile/" + profile.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&full)
+	resp.Body.Close()
+	var payload models.TexturesPayload
+	for _, property := range full.Properties {
+		if property.Name == "textures" {
+			data, _ := base64.StdEncoding.DecodeString(property.Value)
+			json.Unmarshal(data, &payload)
+		}
+	}
+	skinURL := payload.Textures[models.TextureSkin].URL
+	if !strings.HasPrefix(skinURL, base+"/textures/") {
+		t.Fatalf("skin URL = %q, want prefix %q", skinURL, base+"/textures/")
+	}
+	resp, err = http.Get(skinURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(data) == 0 {
+		t.Fatalf("GET %s: status %d", skinURL, resp.StatusCode)
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import "context"
+
+// clientIPKey 上下文中客户端地址的键
+
+type clientIPKey struct{}
+
+// WithClientIP 返回附带客户端地址的上下文
+// 服务器会为每个请求附带解析后的客户端地址，供服务层记录进入服务器的地址等
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext 返回上下文中的客户端地址，未知时返回空字符串
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// baseURLKey 上下文中API地址的键
+
+type baseURLKey struct{}
+
+// WithBaseURL 返回附带API地址的上下文
+// 服务器会为每个请求附带根据请求解析的API地址，未配置TextureBaseURL时材质的URL以此为基础
+func WithBaseURL(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, baseURLKey{}, baseURL)
+}
+
+// BaseURLFromContext 返回上下文中的API地址，未知时返回空字符串
+func BaseURLFromContext(ctx context.Context) string {
+	baseURL, _ := ctx.Value(baseURLKey{}).(string)
+	return baseURL
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// Error 表示服务层返回的错误，对应技术规范中的错误信息格式
+// 服务器根据Status返回HTTP状态码，客户端会将错误响应还原为Error，因此两端均可使用errors.Is判断错误类型
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E9%94%99%E8%AF%AF%E4%BF%A1%E6%81%AF%E6%A0%BC%E5%BC%8F
+
+type Error struct {
+	Status  int    // HTTP状态码
+	Type    string // 错误的简要描述（机器可读），对应error字段
+	Message string // 错误的详细信息（人类可读），对应errorMessage字段
+	Cause   string // 该错误的原因（可选），对应cause字段
+	err     error  // 被包装的底层错误（可选）
+}
+
+// 技术规范中定义的错误类型
+const (
+	ForbiddenOperationException = "ForbiddenOperationException"
+	IllegalArgumentException    = "IllegalArgumentException"
+)
+
+// 服务层返回的错误
+var (
+	ErrInvalidCredentials     = &Error{Status: http.StatusForbidden, Type: ForbiddenOperationException, Message: "Invalid credentials. Invalid username or password."}
+	ErrInvalidToken           = &Error{Status: http.StatusForbidden, Type: ForbiddenOperationException, Message: "Invalid token."}
+	ErrProfileNotOwned        = &Error{Status: http.StatusForbidden, Type: ForbiddenOperationException, Message: "The profile does not belong to the user."}
+	ErrProfileAlreadyAssigned = &Error{Status: http.StatusBadRequest, Type: IllegalArgumentException, Message: "Access token already has a profile assigned."}
+	ErrProfileNameInUse       = &Error{Status: http.StatusBadRequest, Type: IllegalArgumentException, Message: "Profile name already in use."}
+	ErrProfileNotFound        = &Error{Status: http.StatusNotFound, Type: "NotFoundException", Message: "Profile not found."}
+	ErrUnauthorized           = &Error{Status: http.StatusUnauthorized, Type: "Unauthorized", Message: "The request requires user authentication."}
+	ErrRateLimited            = &Error{Status: http.StatusTooManyRequests, Type: "TooManyRequestsException", Message: "Too many requests."}
+	ErrInternal               = &Error{Status: http.StatusInternalServerError, Type: "InternalServerError", Message: "Internal server error."}
+
+	// ErrIllegalArgument 匹配所有IllegalArgumentException类型的错误
+	ErrIllegalArgument = &Error{Status: http.StatusBadRequest, Type: IllegalArgumentException}
+)
+
+// IllegalArgument 将参数错误包装为IllegalArgumentException
+func IllegalArgument(err error) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Type:    IllegalArgumentException,
+		Message: err.Error(),
+		err:     err,
+	}
+}
+
+// ErrorFromResponse 将错误响应还原为Error
+func ErrorFromResponse(status int, resp models.ErrorResponse) *Error {
+	return &Error{
+		Status:  status,
+		Type:    resp.Error,
+		Message: resp.ErrorMessage,
+		Cause:   resp.Cause,
+	}
+}
+
+// Error 实现error接口
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Type
+	}
+	return e.Message
+}
+
+// Unwrap 返回被包装的底层错误
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is 判断是否为同一类错误，错误类型和详细信息均相同即视为同一类，不比较Cause
+// target的详细信息为空时，只比较错误类型
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return e.Type == t.Type && (t.Message == "" || e.Message == t.Message)
+}
+
+// WithCause 返回附带原因的错误副本
+func (e *Error) WithCause(cause string) *Error {
+	copied := *e
+	copied.Cause = cause
+	return &copied
+}
+
+// Response 转换为技术规范中的错误响应
+func (e *Error) Response() models.ErrorResponse {
+	return models.ErrorResponse{
+		Error:        e.Type,
+		ErrorMessage: e.Message,
+		Cause:        e.Cause,
+	}
+}
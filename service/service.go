@@ -1,11 +1,20 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/CycleZero/mc-yggdrasil-go/models"
+	"github.com/CycleZero/mc-yggdrasil-go/passwords"
+	"github.com/CycleZero/mc-yggdrasil-go/signing"
+	"github.com/CycleZero/mc-yggdrasil-go/store"
+	"github.com/CycleZero/mc-yggdrasil-go/textures"
 	"github.com/CycleZero/mc-yggdrasil-go/utils"
 )
 
@@ -14,299 +23,761 @@ import (
 
 type YggdrasilService interface {
 	// Auth 执行认证请求
-	Auth(req models.AuthRequest) (*models.AuthResponse, error)
-	
+	Auth(ctx context.Context, req models.AuthRequest) (*models.AuthResponse, error)
+
 	// Refresh 刷新访问令牌
-	Refresh(req models.RefreshRequest) (*models.AuthResponse, error)
-	
+	Refresh(ctx context.Context, req models.RefreshRequest) (*models.AuthResponse, error)
+
 	// Validate 验证访问令牌是否有效
-	Validate(req models.ValidateRequest) (bool, error)
-	
+	Validate(ctx context.Context, req models.ValidateRequest) (bool, error)
+
 	// Invalidate 使访问令牌失效
-	Invalidate(req models.InvalidateRequest) error
-	
+	Invalidate(ctx context.Context, req models.InvalidateRequest) error
+
 	// Signout 使用用户名和密码登出
-	Signout(req models.SignoutRequest) error
+	Signout(ctx context.Context, req models.SignoutRequest) error
+
+	// Join 记录客户端进入服务器，客户端的地址从上下文中获取（见WithClientIP）
+	Join(ctx context.Context, req models.JoinRequest) error
+
+	// HasJoined 检查客户端是否已进入服务器，未找到匹配的记录时返回nil
+	HasJoined(ctx context.Context, username, serverID, ip string) (*models.Profile, error)
+
+	// GetProfile 根据UUID查询角色，角色不存在时返回nil
+	GetProfile(ctx context.Context, id string, unsigned bool) (*models.Profile, error)
+
+	// GetProfilesByNames 根据名称批量查询角色（不区分大小写），不存在的角色会被忽略
+	GetProfilesByNames(ctx context.Context, names []string) ([]models.Profile, error)
+
+	// UploadTexture 上传角色的材质，accessToken必须属于该角色的所有者
+	UploadTexture(ctx context.Context, accessToken, profileID string, textureType models.TextureType, model models.TextureModel, data []byte) error
+
+	// DeleteTexture 删除角色的材质，accessToken必须属于该角色的所有者
+	DeleteTexture(ctx context.Context, accessToken, profileID string, textureType models.TextureType) error
 }
 
-// MemoryYggdrasilService 是YggdrasilService的内存实现
+// MaxProfileNamesPerQuery 单次批量查询角色的最大数目
+const MaxProfileNamesPerQuery = 10
+
+// 客户端提供的标识的最大长度（字符数），超过时返回IllegalArgumentException，避免存储层写入失败
+const (
+	MaxClientTokenLength = 255
+	MaxServerIDLength    = 255
+)
+
+// StoreYggdrasilService 是基于Store实现的YggdrasilService
+// 协议逻辑与持久化分离，接入其他存储只需实现store.Store接口
+
+type StoreYggdrasilService struct {
+	// Store 用户、角色、令牌等数据的存储
+	Store store.Store
+
+	// Signer 用于对角色属性进行数字签名，为nil时不签名
+	Signer *signing.Signer
+
+	// TextureStore 材质文件存储，默认为内存存储
+	TextureStore textures.Store
+
+	// TextureBaseURL 材质服务的基础URL，材质的URL为 TextureBaseURL + "/textures/" + 哈希值
+	// 为空时使用服务器根据请求解析的API地址（见WithBaseURL），即由同一个服务器提供材质
+	TextureBaseURL string
+
+	// TokenValidDuration 令牌签发后保持有效的时长，超过后令牌暂时失效（无法通过验证，但仍可刷新），为0时不会暂时失效
+	TokenValidDuration time.Duration
+
+	// TokenExpireDuration 令牌签发后到完全失效的时长，为0时永不失效
+	TokenExpireDuration time.Duration
+
+	// MaxTokensPerUser 每个用户最多持有的令牌数量，超出时吊销最早签发的令牌，为0时不限制
+	MaxTokensPerUser int
+
+	// PasswordHasher 计算和验证密码哈希，修改参数后用户的密码会在下次登录时以新参数重新计算
+	PasswordHasher *passwords.Hasher
+
+	// 串行化材质的上传和删除，避免删除仍被其他角色引用的材质文件
+	textureMu sync.Mutex
+}
+
+// GetSigner 返回对角色属性进行数字签名的签名器，服务器未单独配置签名器时使用
+func (s *StoreYggdrasilService) GetSigner() *signing.Signer {
+	return s.Signer
+}
+
+// GetTextureStore 返回材质文件存储，服务器未单独配置材质存储时使用
+func (s *StoreYggdrasilService) GetTextureStore() textures.Store {
+	return s.TextureStore
+}
+
+// MemoryYggdrasilService 是使用内存存储的YggdrasilService
 // 用于演示和测试，实际项目中可能需要持久化存储
 
 type MemoryYggdrasilService struct {
-	// 用户数据存储
-	users map[string]UserCredentials // 用户名 -> 用户凭证
-	
-	// 令牌存储
-	accessTokens map[string]AccessTokenInfo // 访问令牌 -> 令牌信息
-	clientTokens map[string]string // 客户端令牌 -> 访问令牌
-	
-	// 角色存储
-	profiles map[string]*models.Profile // 用户ID -> 角色
-	
-	// 锁，用于并发控制
-	mu sync.RWMutex
+	*StoreYggdrasilService
 }
 
-// UserCredentials 表示用户凭证
+// tokenState 表示访问令牌的状态
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E4%BB%A4%E7%89%8Ctoken
 
-type UserCredentials struct {
-	ID       string
-	Password string
-}
+type tokenState int
+
+const (
+	tokenValid              tokenState = iota // 有效
+	tokenTemporarilyInvalid                   // 暂时失效，只能用于刷新
+	tokenInvalid                              // 完全失效
+)
+
+// 令牌有效期和数量上限的默认值
+const (
+	DefaultTokenValidDuration  = 3 * 24 * time.Hour
+	DefaultTokenExpireDuration = 15 * 24 * time.Hour
+	DefaultMaxTokensPerUser    = 10
+)
 
-// AccessTokenInfo 表示访问令牌信息
+// joinRecordTTL 进入服务器记录的有效期
+const joinRecordTTL = 30 * time.Second
 
-type AccessTokenInfo struct {
-	UserID      string
-	ClientToken string
-	ProfileID   string
-	CreatedAt   time.Time
+// NewStoreYggdrasilService 创建一个基于指定存储的Yggdrasil服务
+func NewStoreYggdrasilService(st store.Store) *StoreYggdrasilService {
+	return &StoreYggdrasilService{
+		Store:        st,
+		TextureStore: textures.NewMemoryStore(),
+
+		TokenValidDuration:  DefaultTokenValidDuration,
+		TokenExpireDuration: DefaultTokenExpireDuration,
+		MaxTokensPerUser:    DefaultMaxTokensPerUser,
+		PasswordHasher:      passwords.NewHasher(),
+	}
 }
 
 // NewMemoryYggdrasilService 创建一个新的内存实现的Yggdrasil服务
 func NewMemoryYggdrasilService() *MemoryYggdrasilService {
 	return &MemoryYggdrasilService{
-		users:        make(map[string]UserCredentials),
-		accessTokens: make(map[string]AccessTokenInfo),
-		clientTokens: make(map[string]string),
-		profiles:     make(map[string]*models.Profile),
+		StoreYggdrasilService: NewStoreYggdrasilService(store.NewMemoryStore()),
 	}
 }
 
 // Auth 实现认证请求
-func (s *MemoryYggdrasilService) Auth(req models.AuthRequest) (*models.AuthResponse, error) {
-	s.mu.RLock()
-	userCreds, exists := s.users[req.Username]
-	s.mu.RUnlock()
-	
+func (s *StoreYggdrasilService) Auth(ctx context.Context, req models.AuthRequest) (*models.AuthResponse, error) {
+	if utf8.RuneCountInString(req.ClientToken) > MaxClientTokenLength {
+		return nil, IllegalArgument(fmt.Errorf("clientToken must not be longer than %d characters.", MaxClientTokenLength))
+	}
+
 	// 检查用户是否存在且密码正确
-	if !exists || userCreds.Password != req.Password {
-		return nil, errors.New("Invalid credentials. Invalid username or password.")
-	}
-	
-	s.mu.RLock()
-	profile, profileExists := s.profiles[userCreds.ID]
-	s.mu.RUnlock()
-	
-	// 检查角色是否存在
-	if !profileExists {
-		return nil, errors.New("No profile found for user")
-	}
-	
-	// 生成访问令牌和客户端令牌
-	accessToken := utils.GenerateUUID()
-	clientToken := req.ClientToken
-	if clientToken == "" {
-		clientToken = utils.GenerateUUID()
-	}
-	
-	// 存储令牌信息
-	s.mu.Lock()
-	s.accessTokens[accessToken] = AccessTokenInfo{
-		UserID:      userCreds.ID,
-		ClientToken: clientToken,
-		ProfileID:   profile.ID,
+	user, err := s.checkCredentials(ctx, req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := s.Store.ListProfiles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 仅当用户只有一个角色时自动选择该角色，否则令牌不绑定角色
+	tokenInfo := store.AccessTokenInfo{
+		AccessToken: utils.GenerateUUID(),
+		ClientToken: req.ClientToken,
+		UserID:      user.ID,
 		CreatedAt:   time.Now(),
 	}
-	s.clientTokens[clientToken] = accessToken
-	s.mu.Unlock()
-	
-	// 构建响应
+	if tokenInfo.ClientToken == "" {
+		tokenInfo.ClientToken = utils.GenerateUUID()
+	}
+	if len(profiles) == 1 {
+		tokenInfo.ProfileID = profiles[0].ID
+	}
+
+	// 顺便清理已完全失效的令牌
+	if s.TokenExpireDuration > 0 {
+		if err := s.Store.DeleteTokensBefore(ctx, tokenInfo.CreatedAt.Add(-s.TokenExpireDuration)); err != nil {
+			return nil, err
+		}
+	}
+	// 为新令牌腾出空间
+	if err := s.revokeTokens(ctx, user.ID, tokenInfo.ClientToken); err != nil {
+		return nil, err
+	}
+	if err := s.Store.CreateToken(ctx, tokenInfo); err != nil {
+		return nil, err
+	}
+
+	// 构建响应，角色附带textures属性
 	resp := &models.AuthResponse{
-		AccessToken:  accessToken,
-		ClientToken:  clientToken,
-		SelectedProfile: profile,
+		AccessToken:       tokenInfo.AccessToken,
+		ClientToken:       tokenInfo.ClientToken,
+		AvailableProfiles: make([]models.Profile, 0, len(profiles)),
+	}
+	for _, availableProfile := range profiles {
+		completed, err := s.completeProfile(ctx, availableProfile, false)
+		if err != nil {
+			return nil, err
+		}
+		resp.AvailableProfiles = append(resp.AvailableProfiles, *completed)
+		if availableProfile.ID == tokenInfo.ProfileID {
+			resp.SelectedProfile = completed
+		}
 	}
-	
+
 	// 如果请求了用户信息，添加用户信息
 	if req.RequestUser {
-		resp.User = &models.User{
-			ID: userCreds.ID,
-			Properties: []models.Property{
-				{
-					Name:  "preferredLanguage",
-					Value: "en",
-				},
-			},
-		}
+		resp.User = newUser(user.ID)
 	}
-	
+
 	return resp, nil
 }
 
 // Refresh 实现刷新访问令牌
-func (s *MemoryYggdrasilService) Refresh(req models.RefreshRequest) (*models.AuthResponse, error) {
-	s.mu.RLock()
-	tokenInfo, exists := s.accessTokens[req.AccessToken]
-	s.mu.RUnlock()
-	
-	// 检查令牌是否存在
-	if !exists {
-		return nil, errors.New("Invalid token.")
-	}
-	
+func (s *StoreYggdrasilService) Refresh(ctx context.Context, req models.RefreshRequest) (*models.AuthResponse, error) {
+	// 检查令牌是否存在，暂时失效的令牌仍可刷新
+	tokenInfo, err := s.getToken(ctx, req.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if s.tokenState(*tokenInfo) == tokenInvalid {
+		return nil, ErrInvalidToken
+	}
+
 	// 检查客户端令牌是否匹配
 	if req.ClientToken != "" && req.ClientToken != tokenInfo.ClientToken {
-		return nil, errors.New("Invalid token.")
-	}
-	
-	s.mu.RLock()
-	profile, profileExists := s.profiles[tokenInfo.UserID]
-	s.mu.RUnlock()
-	
-	// 检查角色是否存在
-	if !profileExists {
-		return nil, errors.New("No profile found for user")
-	}
-	
-	// 生成新的访问令牌
-	newAccessToken := utils.GenerateUUID()
-	
-	// 更新令牌信息
-	s.mu.Lock()
-	// 删除旧的访问令牌
-	delete(s.accessTokens, req.AccessToken)
-	// 删除旧的客户端令牌映射
-	if oldAccessToken, exists := s.clientTokens[tokenInfo.ClientToken]; exists && oldAccessToken == req.AccessToken {
-		delete(s.clientTokens, tokenInfo.ClientToken)
-	}
-	// 存储新的访问令牌
-	s.accessTokens[newAccessToken] = AccessTokenInfo{
-		UserID:      tokenInfo.UserID,
+		return nil, ErrInvalidToken
+	}
+
+	// 选择角色时，令牌必须尚未绑定角色，且所选角色必须属于该用户
+	profileID := tokenInfo.ProfileID
+	if req.SelectedProfile != nil {
+		if profileID != "" {
+			return nil, ErrProfileAlreadyAssigned
+		}
+		profile, err := s.Store.GetProfile(ctx, req.SelectedProfile.ID)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && profile.UserID != tokenInfo.UserID) {
+			return nil, ErrProfileNotOwned
+		}
+		if err != nil {
+			return nil, err
+		}
+		profileID = profile.ID
+	}
+
+	// 删除旧的访问令牌，并发刷新同一个令牌时只有一方成功
+	if err := s.Store.DeleteToken(ctx, req.AccessToken); errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	// 签发新的访问令牌
+	newTokenInfo := store.AccessTokenInfo{
+		AccessToken: utils.GenerateUUID(),
 		ClientToken: tokenInfo.ClientToken,
-		ProfileID:   tokenInfo.ProfileID,
+		UserID:      tokenInfo.UserID,
+		ProfileID:   profileID,
 		CreatedAt:   time.Now(),
 	}
-	s.clientTokens[tokenInfo.ClientToken] = newAccessToken
-	s.mu.Unlock()
-	
-	// 构建响应
+	if err := s.Store.CreateToken(ctx, newTokenInfo); err != nil {
+		return nil, err
+	}
+
+	// 构建响应，已绑定的角色附带textures属性
 	resp := &models.AuthResponse{
-		AccessToken:     newAccessToken,
-		ClientToken:     tokenInfo.ClientToken,
-		SelectedProfile: profile,
+		AccessToken: newTokenInfo.AccessToken,
+		ClientToken: newTokenInfo.ClientToken,
 	}
-	
+	if profileID != "" {
+		profile, err := s.Store.GetProfile(ctx, profileID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		if profile != nil {
+			if resp.SelectedProfile, err = s.completeProfile(ctx, *profile, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// 如果请求了用户信息，添加用户信息
 	if req.RequestUser {
-		resp.User = &models.User{
-			ID: tokenInfo.UserID,
-			Properties: []models.Property{
-				{
-					Name:  "preferredLanguage",
-					Value: "en",
-				},
-			},
-		}
+		resp.User = newUser(tokenInfo.UserID)
 	}
-	
+
 	return resp, nil
 }
 
 // Validate 实现验证访问令牌
-func (s *MemoryYggdrasilService) Validate(req models.ValidateRequest) (bool, error) {
-	s.mu.RLock()
-	tokenInfo, exists := s.accessTokens[req.AccessToken]
-	s.mu.RUnlock()
-	
-	// 检查令牌是否存在
-	if !exists {
+func (s *StoreYggdrasilService) Validate(ctx context.Context, req models.ValidateRequest) (bool, error) {
+	// 检查令牌是否存在且有效
+	tokenInfo, err := s.getToken(ctx, req.AccessToken)
+	if errors.Is(err, ErrInvalidToken) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if s.tokenState(*tokenInfo) != tokenValid {
 		return false, nil
 	}
-	
+
 	// 检查客户端令牌是否匹配
 	if req.ClientToken != "" && req.ClientToken != tokenInfo.ClientToken {
 		return false, nil
 	}
-	
+
 	return true, nil
 }
 
 // Invalidate 实现使访问令牌失效
-func (s *MemoryYggdrasilService) Invalidate(req models.InvalidateRequest) error {
-	s.mu.RLock()
-	tokenInfo, exists := s.accessTokens[req.AccessToken]
-	s.mu.RUnlock()
-	
-	// 检查令牌是否存在
-	if !exists {
-		return errors.New("Invalid token.")
-	}
-	
+func (s *StoreYggdrasilService) Invalidate(ctx context.Context, req models.InvalidateRequest) error {
+	tokenInfo, err := s.getToken(ctx, req.AccessToken)
+	if err != nil {
+		return err
+	}
+
 	// 检查客户端令牌是否匹配
 	if req.ClientToken != tokenInfo.ClientToken {
-		return errors.New("Invalid token.")
+		return ErrInvalidToken
 	}
-	
+
 	// 删除令牌
-	s.mu.Lock()
-	delete(s.accessTokens, req.AccessToken)
-	if oldAccessToken, exists := s.clientTokens[tokenInfo.ClientToken]; exists && oldAccessToken == req.AccessToken {
-		delete(s.clientTokens, tokenInfo.ClientToken)
+	if err := s.Store.DeleteToken(ctx, req.AccessToken); errors.Is(err, store.ErrNotFound) {
+		return ErrInvalidToken
+	} else if err != nil {
+		return err
 	}
-	s.mu.Unlock()
-	
+
 	return nil
 }
 
 // Signout 实现使用用户名和密码登出
-func (s *MemoryYggdrasilService) Signout(req models.SignoutRequest) error {
-	s.mu.RLock()
-	userCreds, exists := s.users[req.Username]
-	s.mu.RUnlock()
-	
+func (s *StoreYggdrasilService) Signout(ctx context.Context, req models.SignoutRequest) error {
 	// 检查用户是否存在且密码正确
-	if !exists || userCreds.Password != req.Password {
-		return errors.New("Invalid credentials. Invalid username or password.")
-	}
-	
-	// 找出并删除该用户的所有访问令牌
-	s.mu.Lock()
-	for accessToken, tokenInfo := range s.accessTokens {
-		if tokenInfo.UserID == userCreds.ID {
-			delete(s.accessTokens, accessToken)
-			if oldAccessToken, exists := s.clientTokens[tokenInfo.ClientToken]; exists && oldAccessToken == accessToken {
-				delete(s.clientTokens, tokenInfo.ClientToken)
+	user, err := s.checkCredentials(ctx, req.Username, req.Password)
+	if err != nil {
+		return err
+	}
+
+	// 删除该用户的所有访问令牌
+	return s.Store.DeleteUserTokens(ctx, user.ID)
+}
+
+// Join 实现记录客户端进入服务器
+func (s *StoreYggdrasilService) Join(ctx context.Context, req models.JoinRequest) error {
+	if utf8.RuneCountInString(req.ServerID) > MaxServerIDLength {
+		return IllegalArgument(fmt.Errorf("serverId must not be longer than %d characters.", MaxServerIDLength))
+	}
+
+	tokenInfo, err := s.getToken(ctx, req.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	// 检查令牌是否有效，且绑定的角色与请求中的角色一致
+	if s.tokenState(*tokenInfo) != tokenValid || tokenInfo.ProfileID == "" || tokenInfo.ProfileID != req.SelectedProfile {
+		return ErrInvalidToken
+	}
+
+	// 顺便清理已过期的记录
+	now := time.Now()
+	if err := s.Store.DeleteJoinRecordsBefore(ctx, now.Add(-joinRecordTTL)); err != nil {
+		return err
+	}
+
+	// 记录进入服务器的信息，供服务端验证
+	return s.Store.PutJoinRecord(ctx, store.JoinRecord{
+		ServerID:  req.ServerID,
+		ProfileID: tokenInfo.ProfileID,
+		IP:        ClientIPFromContext(ctx),
+		CreatedAt: now,
+	})
+}
+
+// HasJoined 实现检查客户端是否已进入服务器
+func (s *StoreYggdrasilService) HasJoined(ctx context.Context, username, serverID, ip string) (*models.Profile, error) {
+	// 检查记录是否存在且未过期
+	record, err := s.Store.GetJoinRecord(ctx, serverID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(record.CreatedAt) > joinRecordTTL {
+		return nil, nil
+	}
+
+	// 提供了ip时，需与进入服务器时记录的地址一致
+	if ip != "" && ip != record.IP {
+		return nil, nil
+	}
+
+	// 检查角色名称是否与记录中的角色一致
+	profile, err := s.Store.GetProfile(ctx, record.ProfileID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if profile.Name != username {
+		return nil, nil
+	}
+
+	// 根据技术规范，服务端验证客户端时需要附带属性的数字签名
+	return s.completeProfile(ctx, *profile, true)
+}
+
+// GetProfile 实现根据UUID查询角色
+func (s *StoreYggdrasilService) GetProfile(ctx context.Context, id string, unsigned bool) (*models.Profile, error) {
+	profile, err := s.Store.GetProfile(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeProfile(ctx, *profile, !unsigned)
+}
+
+// GetProfilesByNames 实现根据名称批量查询角色
+func (s *StoreYggdrasilService) GetProfilesByNames(ctx context.Context, names []string) ([]models.Profile, error) {
+	// 根据技术规范，需限制单次查询的角色数目
+	if len(names) > MaxProfileNamesPerQuery {
+		return nil, IllegalArgument(fmt.Errorf("Not more than %d profile names per call are allowed.", MaxProfileNamesPerQuery))
+	}
+
+	profiles := make([]models.Profile, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		profile, err := s.Store.GetProfileByName(ctx, name)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// 响应中的角色不包含属性
+		profiles = append(profiles, models.Profile{
+			ID:   profile.ID,
+			Name: profile.Name,
+		})
+	}
+
+	return profiles, nil
+}
+
+// UploadTexture 实现上传角色的材质
+func (s *StoreYggdrasilService) UploadTexture(ctx context.Context, accessToken, profileID string, textureType models.TextureType, model models.TextureModel, data []byte) error {
+	if err := s.checkProfileOwner(ctx, accessToken, profileID); err != nil {
+		return err
+	}
+
+	// 校验并规范化材质，材质以哈希值寻址，相同的材质只存储一份
+	data, hash, err := textures.Normalize(data, textureType)
+	if errors.Is(err, textures.ErrInvalidTexture) {
+		return IllegalArgument(err)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 持有锁写入存储，避免与releaseTexture并发删除同一材质
+	s.textureMu.Lock()
+	defer s.textureMu.Unlock()
+
+	oldTexture, err := s.profileTexture(ctx, profileID, textureType)
+	if err != nil {
+		return err
+	}
+
+	if err := s.TextureStore.Put(hash, data); err != nil {
+		return err
+	}
+	if err := s.Store.SetProfileTexture(ctx, store.ProfileTexture{
+		ProfileID: profileID,
+		Type:      textureType,
+		Hash:      hash,
+		Model:     model,
+	}); err != nil {
+		return err
+	}
+
+	if oldTexture != nil && oldTexture.Hash != hash {
+		return s.releaseTexture(ctx, oldTexture.Hash)
+	}
+	return nil
+}
+
+// DeleteTexture 实现删除角色的材质
+func (s *StoreYggdrasilService) DeleteTexture(ctx context.Context, accessToken, profileID string, textureType models.TextureType) error {
+	if err := s.checkProfileOwner(ctx, accessToken, profileID); err != nil {
+		return err
+	}
+
+	s.textureMu.Lock()
+	defer s.textureMu.Unlock()
+
+	oldTexture, err := s.profileTexture(ctx, profileID, textureType)
+	if err != nil || oldTexture == nil {
+		return err
+	}
+	if err := s.Store.DeleteProfileTexture(ctx, profileID, textureType); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	return s.releaseTexture(ctx, oldTexture.Hash)
+}
+
+// profileTexture 返回角色指定类型的材质，不存在时返回nil
+func (s *StoreYggdrasilService) profileTexture(ctx context.Context, profileID string, textureType models.TextureType) (*store.ProfileTexture, error) {
+	profileTextures, err := s.Store.GetProfileTextures(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, texture := range profileTextures {
+		if texture.Type == textureType {
+			return &texture, nil
+		}
+	}
+	return nil, nil
+}
+
+// releaseTexture 在材质不再被任何角色使用时将其从存储中删除
+// 调用时需持有textureMu
+func (s *StoreYggdrasilService) releaseTexture(ctx context.Context, hash string) error {
+	inUse, err := s.Store.TextureInUse(ctx, hash)
+	if err != nil || inUse {
+		return err
+	}
+	return s.TextureStore.Delete(hash)
+}
+
+// checkCredentials 检查用户名和密码，返回用户凭证
+// 密码哈希为旧版格式或参数已过时，以当前参数重新计算并保存
+func (s *StoreYggdrasilService) checkCredentials(ctx context.Context, username, password string) (*store.UserCredentials, error) {
+	user, err := s.Store.GetUser(ctx, username)
+	if errors.Is(err, store.ErrNotFound) {
+		// 用户不存在时同样计算一次哈希，避免通过响应时间判断用户名是否存在
+		s.PasswordHasher.Hash(password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 无法识别的密码哈希只记录日志，避免通过错误响应区分用户
+	ok, rehash, err := s.PasswordHasher.Verify(user.Password, password)
+	if err != nil {
+		log.Printf("验证用户 %s 的密码失败: %v\n", username, err)
+		return nil, ErrInvalidCredentials
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if rehash {
+		// 更新失败不影响本次登录，下次登录时会再次尝试
+		if encoded, err := s.PasswordHasher.Hash(password); err != nil {
+			log.Printf("计算密码哈希失败: %v\n", err)
+		} else {
+			user.Password = encoded
+			if err := s.Store.UpdateUser(ctx, *user); err != nil {
+				log.Printf("更新用户 %s 的密码哈希失败: %v\n", username, err)
 			}
 		}
 	}
-	s.mu.Unlock()
-	
+
+	return user, nil
+}
+
+// getToken 查询访问令牌，令牌不存在时返回ErrInvalidToken
+func (s *StoreYggdrasilService) getToken(ctx context.Context, accessToken string) (*store.AccessTokenInfo, error) {
+	tokenInfo, err := s.Store.GetToken(ctx, accessToken)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	return tokenInfo, err
+}
+
+// checkProfileOwner 检查访问令牌是否属于角色的所有者
+func (s *StoreYggdrasilService) checkProfileOwner(ctx context.Context, accessToken, profileID string) error {
+	tokenInfo, err := s.getToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+	if s.tokenState(*tokenInfo) != tokenValid {
+		return ErrInvalidToken
+	}
+
+	profile, err := s.Store.GetProfile(ctx, profileID)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrProfileNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if profile.UserID != tokenInfo.UserID {
+		return ErrProfileNotOwned
+	}
+
 	return nil
 }
 
-// 添加方法用于管理用户和角色（仅用于演示和测试）
+// tokenState 根据签发时间计算令牌的状态
+func (s *StoreYggdrasilService) tokenState(tokenInfo store.AccessTokenInfo) tokenState {
+	age := time.Since(tokenInfo.CreatedAt)
+	if s.TokenExpireDuration > 0 && age > s.TokenExpireDuration {
+		return tokenInvalid
+	}
+	if s.TokenValidDuration > 0 && age > s.TokenValidDuration {
+		return tokenTemporarilyInvalid
+	}
+	return tokenValid
+}
+
+// revokeTokens 为用户即将签发的新令牌腾出空间
+// 重复使用的客户端令牌替换其原有的访问令牌，并吊销最早签发的令牌，使令牌数量低于MaxTokensPerUser
+func (s *StoreYggdrasilService) revokeTokens(ctx context.Context, userID, clientToken string) error {
+	tokens, err := s.Store.ListTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var revoked, kept []store.AccessTokenInfo
+	for _, tokenInfo := range tokens {
+		if tokenInfo.ClientToken == clientToken {
+			revoked = append(revoked, tokenInfo)
+		} else {
+			kept = append(kept, tokenInfo)
+		}
+	}
+	if s.MaxTokensPerUser > 0 && len(kept) >= s.MaxTokensPerUser {
+		revoked = append(revoked, kept[:len(kept)-s.MaxTokensPerUser+1]...)
+	}
+
+	for _, tokenInfo := range revoked {
+		if err := s.Store.DeleteToken(ctx, tokenInfo.AccessToken); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeProfile 返回附带textures属性的角色
+// signed为true且配置了签名器时，为属性附带数字签名
+func (s *StoreYggdrasilService) completeProfile(ctx context.Context, profile store.ProfileInfo, signed bool) (*models.Profile, error) {
+	profileTextures, err := s.Store.GetProfileTextures(ctx, profile.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 材质的URL指向本服务器提供的材质服务
+	baseURL := s.TextureBaseURL
+	if baseURL == "" {
+		baseURL = BaseURLFromContext(ctx)
+	}
+	builder := textures.NewPropertyBuilder(baseURL, &models.Profile{ID: profile.ID, Name: profile.Name})
+	for _, texture := range profileTextures {
+		switch texture.Type {
+		case models.TextureSkin:
+			builder.Skin(texture.Hash, texture.Model)
+		case models.TextureCape:
+			builder.Cape(texture.Hash)
+		}
+	}
+
+	texturesProperty, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	completed := &models.Profile{
+		ID:   profile.ID,
+		Name: profile.Name,
+		Properties: []models.Property{
+			texturesProperty,
+			{
+				// 告知启动器该角色可以上传的材质类型
+				Name:  "uploadableTextures",
+				Value: "skin,cape",
+			},
+		},
+	}
+
+	if signed && s.Signer != nil {
+		if err := s.Signer.SignProperties(completed.Properties); err != nil {
+			return nil, err
+		}
+	}
+
+	return completed, nil
+}
+
+// newUser 返回认证和刷新响应中的用户信息
+func newUser(userID string) *models.User {
+	return &models.User{
+		ID: userID,
+		Properties: []models.Property{
+			{
+				Name:  "preferredLanguage",
+				Value: "en",
+			},
+		},
+	}
+}
+
+// 添加方法用于管理用户和角色
 
 // AddUser 添加一个用户
-func (s *MemoryYggdrasilService) AddUser(username, password string) (string, error) {
+func (s *StoreYggdrasilService) AddUser(username, password string) (string, error) {
 	userID := utils.GenerateUUID()
-	
-	s.mu.Lock()
-	s.users[username] = UserCredentials{
+
+	// 只保存加盐的密码哈希
+	encoded, err := s.PasswordHasher.Hash(password)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.Store.CreateUser(context.Background(), store.UserCredentials{
 		ID:       userID,
-		Password: password,
+		Username: username,
+		Password: encoded,
+	}); err != nil {
+		return "", err
 	}
-	s.mu.Unlock()
-	
+
 	return userID, nil
 }
 
-// AddProfile 添加一个角色
-func (s *MemoryYggdrasilService) AddProfile(userID, name string) (*models.Profile, error) {
+// AddProfile 为用户添加一个角色，一个用户可以拥有多个角色
+func (s *StoreYggdrasilService) AddProfile(userID, name string) (*models.Profile, error) {
 	// 生成与离线验证系统兼容的UUID
 	profileID, err := utils.GenerateOfflinePlayerUUID(name)
 	if err != nil {
 		return nil, err
 	}
-	
-	profile := &models.Profile{
+
+	// 角色名称不区分大小写，且不能重复
+	err = s.Store.CreateProfile(context.Background(), store.ProfileInfo{
+		ID:        profileID,
+		UserID:    userID,
+		Name:      name,
+		CreatedAt: time.Now(),
+	})
+	if errors.Is(err, store.ErrConflict) {
+		return nil, ErrProfileNameInUse
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Profile{
 		ID:   profileID,
 		Name: name,
-	}
-	
-	s.mu.Lock()
-	s.profiles[userID] = profile
-	s.mu.Unlock()
-	
-	return profile, nil
-}
\ No newline at end of file
+	}, nil
+}
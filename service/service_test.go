@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+	"github.com/CycleZero/mc-yggdrasil-go/store"
+)
+
+// newTestService 创建使用内存存储的服务，令牌1小时后暂时失效，2小时后完全失效
+func newTestService(t *testing.T) *StoreYggdrasilService {
+	t.Helper()
+	s := NewStoreYggdrasilService(store.NewMemoryStore())
+	s.PasswordHasher.Iterations = 1
+	s.TokenValidDuration = time.Hour
+	s.TokenExpireDuration = 2 * time.Hour
+	return s
+}
+
+// mustAddUser 添加用户，密码为password
+func mustAddUser(t *testing.T, s *StoreYggdrasilService, username string) string {
+	t.Helper()
+	userID, err := s.AddUser(username, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userID
+}
+
+// mustAddProfile 为用户添加角色
+func mustAddProfile(t *testing.T, s *StoreYggdrasilService, userID, name string) *models.Profile {
+	t.Helper()
+	profile, err := s.AddProfile(userID, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+// putToken 直接向存储写入签发于age之前的令牌
+func putToken(t *testing.T, s *StoreYggdrasilService, accessToken, userID string, age time.Duration) {
+	t.Helper()
+	tokenInfo := store.AccessTokenInfo{
+		AccessToken: accessToken,
+		ClientToken: "client-" + accessToken,
+		UserID:      userID,
+		CreatedAt:   time.Now().Add(-age),
+	}
+	if err := s.Store.CreateToken(context.Background(), tokenInfo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		age       time.Duration
+		valid     bool
+		refreshed bool
+	}{
+		{"Valid", time.Minute, true, true},
+		{"TemporarilyInvalid", 90 * time.Minute, false, true},
+		{"Invalid", 3 * time.Hour, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t)
+			userID := mustAddUser(t, s, "player@example.com")
+			putToken(t, s, "token", userID, tt.age)
+
+			valid, err := s.Validate(ctx, models.ValidateRequest{AccessToken: "token"})
+			if err != nil || valid != tt.valid {
+				t.Fatalf("Validate = %v, %v, want %v", valid, err, tt.valid)
+			}
+
+			resp, err := s.Refresh(ctx, models.RefreshRequest{AccessToken: "token", ClientToken: "client-token"})
+			if !tt.refreshed {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Refresh error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Refresh: %v", err)
+			}
+			if resp.ClientToken != "client-token" {
+				t.Errorf("refreshed clientToken = %q, want client-token", resp.ClientToken)
+			}
+			// 刷新得到的新令牌有效
+			if valid, err := s.Validate(ctx, models.ValidateRequest{AccessToken: resp.AccessToken}); err != nil || !valid {
+				t.Errorf("Validate(refreshed) = %v, %v, want true", valid, err)
+			}
+		})
+	}
+}
+
+func TestRefreshIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	userID := mustAddUser(t, s, "player@example.com")
+	putToken(t, s, "token", userID, time.Minute)
+
+	if _, err := s.Refresh(ctx, models.RefreshRequest{AccessToken: "token"}); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if _, err := s.Refresh(ctx, models.RefreshRequest{AccessToken: "token"}); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("second Refresh error = %v, want ErrInvalidToken", err)
+	}
+	if valid, _ := s.Validate(ctx, models.ValidateRequest{AccessToken: "token"}); valid {
+		t.Fatal("refreshed token is still valid")
+	}
+}
+
+func TestRefreshSelectsProfile(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	userID := mustAddUser(t, s, "player@example.com")
+	mustAddProfile(t, s, userID, "First")
+	second := mustAddProfile(t, s, userID, "Second")
+	otherID := mustAddUser(t, s, "other@example.com")
+	foreign := mustAddProfile(t, s, otherID, "Foreign")
+
+	// 用户有多个角色时，认证得到的令牌不绑定角色
+	auth, err := s.Auth(ctx, models.AuthRequest{Username: "player@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.SelectedProfile != nil || len(auth.AvailableProfiles) != 2 {
+		t.Fatalf("Auth selected %+v from %d profiles", auth.SelectedProfile, len(auth.AvailableProfiles))
+	}
+
+	// 不能选择其他用户的角色或不存在的角色，失败时令牌不会被消耗
+	for _, id := range []string{foreign.ID, "00000000000000000000000000000000"} {
+		req := models.RefreshRequest{AccessToken: auth.AccessToken, SelectedProfile: &models.Profile{ID: id}}
+		if _, err := s.Refresh(ctx, req); !errors.Is(err, ErrProfileNotOwned) {
+			t.Fatalf("Refresh selecting %s = %v, want ErrProfileNotOwned", id, err)
+		}
+	}
+
+	req := models.RefreshRequest{AccessToken: auth.AccessToken, SelectedProfile: &models.Profile{ID: second.ID, Name: second.Name}}
+	refreshed, err := s.Refresh(ctx, req)
+	if err != nil {
+		t.Fatalf("Refresh selecting %s: %v", second.ID, err)
+	}
+	if refreshed.SelectedProfile == nil || refreshed.SelectedProfile.ID != second.ID {
+		t.Fatalf("refreshed selectedProfile = %+v, want %s", refreshed.SelectedProfile, second.ID)
+	}
+
+	// 令牌绑定角色后，可以用于进入服务器
+	join := models.JoinRequest{AccessToken: refreshed.AccessToken, SelectedProfile: second.ID, ServerID: "server"}
+	if err := s.Join(ctx, join); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+
+	// 已绑定角色的令牌不能再次选择角色
+	req = models.RefreshRequest{AccessToken: refreshed.AccessToken, SelectedProfile: &models.Profile{ID: second.ID}}
+	if _, err := s.Refresh(ctx, req); !errors.Is(err, ErrProfileAlreadyAssigned) {
+		t.Fatalf("Refresh selecting again = %v, want ErrProfileAlreadyAssigned", err)
+	}
+
+	// 不选择角色时，刷新后的令牌保持原有的角色
+	kept, err := s.Refresh(ctx, models.RefreshRequest{AccessToken: refreshed.AccessToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.SelectedProfile == nil || kept.SelectedProfile.ID != second.ID {
+		t.Fatalf("selectedProfile after plain refresh = %+v, want %s", kept.SelectedProfile, second.ID)
+	}
+}
+
+// authenticate 以指定的客户端令牌认证，返回访问令牌
+func authenticate(t *testing.T, s *StoreYggdrasilService, clientToken string) string {
+	t.Helper()
+	resp, err := s.Auth(context.Background(), models.AuthRequest{Username: "player@example.com", Password: "password", ClientToken: clientToken})
+	if err != nil {
+		t.Fatalf("Auth: %v", err)
+	}
+	return resp.AccessToken
+}
+
+// isValid 检查访问令牌是否有效
+func isValid(t *testing.T, s *StoreYggdrasilService, accessToken string) bool {
+	t.Helper()
+	valid, err := s.Validate(context.Background(), models.ValidateRequest{AccessToken: accessToken})
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	return valid
+}
+
+func TestAuthRevokesOldestTokens(t *testing.T) {
+	s := newTestService(t)
+	s.MaxTokensPerUser = 3
+	mustAddUser(t, s, "player@example.com")
+
+	accessTokens := make([]string, 5)
+	for i := range accessTokens {
+		accessTokens[i] = authenticate(t, s, "")
+	}
+
+	// 只保留最近签发的MaxTokensPerUser个令牌
+	for i, accessToken := range accessTokens {
+		if want := i >= 2; isValid(t, s, accessToken) != want {
+			t.Errorf("token %d valid = %v, want %v", i, !want, want)
+		}
+	}
+}
+
+func TestAuthReplacesClientToken(t *testing.T) {
+	s := newTestService(t)
+	s.MaxTokensPerUser = 2
+	mustAddUser(t, s, "player@example.com")
+
+	first := authenticate(t, s, "launcher")
+	other := authenticate(t, s, "other")
+	second := authenticate(t, s, "launcher")
+
+	// 重复使用的客户端令牌只替换其原有的访问令牌，其他客户端的令牌不受影响
+	if isValid(t, s, first) {
+		t.Error("previous token of the reused clientToken is still valid")
+	}
+	if !isValid(t, s, other) {
+		t.Error("token of another clientToken was revoked")
+	}
+	if !isValid(t, s, second) {
+		t.Error("new token is not valid")
+	}
+}
@@ -0,0 +1,138 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// DefaultKeySize 生成密钥对时使用的默认长度（位）
+const DefaultKeySize = 4096
+
+// Signer 使用RSA私钥对属性值进行数字签名
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E7%AD%BE%E5%90%8D%E5%AF%86%E9%92%A5%E5%AF%B9
+
+type Signer struct {
+	privateKey *rsa.PrivateKey
+}
+
+// NewSigner 使用已有的RSA私钥创建签名器
+func NewSigner(privateKey *rsa.PrivateKey) *Signer {
+	return &Signer{privateKey: privateKey}
+}
+
+// GenerateSigner 生成一个新的RSA密钥对并创建签名器
+func GenerateSigner(bits int) (*Signer, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(privateKey), nil
+}
+
+// LoadSigner 从PEM文件中加载RSA私钥（支持PKCS#1和PKCS#8格式）
+func LoadSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in key file")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigner(privateKey), nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key is not an RSA key")
+		}
+		return NewSigner(privateKey), nil
+	default:
+		return nil, errors.New("unsupported PEM block type: " + block.Type)
+	}
+}
+
+// LoadOrGenerateSigner 从PEM文件中加载RSA私钥，文件不存在时生成新的密钥对并保存
+func LoadOrGenerateSigner(path string, bits int) (*Signer, error) {
+	signer, err := LoadSigner(path)
+	if err == nil {
+		return signer, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	signer, err = GenerateSigner(bits)
+	if err != nil {
+		return nil, err
+	}
+	if err := signer.Save(path); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// Save 将RSA私钥以PKCS#8 PEM格式保存到文件
+func (s *Signer) Save(path string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(s.privateKey)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+}
+
+// PublicKeyPEM 返回PEM格式的公钥，用于API元数据中的signaturePublickey
+func (s *Signer) PublicKeyPEM() (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&s.privateKey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// Sign 使用SHA1withRSA对数据进行签名，返回Base64编码的签名
+func (s *Signer) Sign(data string) (string, error) {
+	hash := sha1.Sum([]byte(data))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA1, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// SignProperties 为属性列表中的每个属性填充数字签名
+func (s *Signer) SignProperties(properties []models.Property) error {
+	for i := range properties {
+		signature, err := s.Sign(properties[i].Value)
+		if err != nil {
+			return err
+		}
+		properties[i].Signature = signature
+	}
+	return nil
+}
@@ -0,0 +1,489 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// 文件存储使用的文件名
+const (
+	snapshotFileName = "snapshot.json" // 快照
+	journalFileName  = "journal.jsonl" // 日志，每行一条修改记录
+)
+
+// DefaultCompactThreshold 日志条目数的默认压缩阈值
+const DefaultCompactThreshold = 1000
+
+// FileStore 是Store的文件实现，不依赖外部数据库
+// 数据保存在内存中，每次修改都追加写入日志并同步到磁盘，日志达到一定长度后压缩为快照
+// 快照先写入临时文件并同步到磁盘后再重命名，进程被强制结束或断电后不会丢失已完成的修改
+// 进入服务器记录的有效期很短，只保存在内存中
+
+type FileStore struct {
+	Dir string // 数据文件所在的目录
+
+	// CompactThreshold 日志条目数达到该值时压缩为快照，为0时只在调用Compact或Close时压缩
+	CompactThreshold int
+
+	mem     *MemoryStore
+	journal *os.File
+	size    int64  // 日志中已确认的记录的总长度，新记录从该位置写入
+	seq     uint64 // 最后一条日志的序号
+	entries int    // 上次压缩后写入的日志条目数
+	failed  error  // 日志无法恢复到一致状态时的错误，此后拒绝所有修改
+
+	// 串行化所有修改，保证日志顺序与内存中的修改顺序一致
+	mu sync.Mutex
+}
+
+// 日志记录的操作类型
+const (
+	opCreateUser           = "createUser"
+	opUpdateUser           = "updateUser"
+	opCreateProfile        = "createProfile"
+	opCreateToken          = "createToken"
+	opDeleteToken          = "deleteToken"
+	opDeleteUserTokens     = "deleteUserTokens"
+	opDeleteTokensBefore   = "deleteTokensBefore"
+	opSetProfileTexture    = "setProfileTexture"
+	opDeleteProfileTexture = "deleteProfileTexture"
+)
+
+// journalEntry 表示日志中的一条修改记录
+
+type journalEntry struct {
+	Seq         uint64             `json:"seq"`
+	Op          string             `json:"op"`
+	User        *UserCredentials   `json:"user,omitempty"`
+	Profile     *ProfileInfo       `json:"profile,omitempty"`
+	Token       *AccessTokenInfo   `json:"token,omitempty"`
+	Texture     *ProfileTexture    `json:"texture,omitempty"`
+	Key         string             `json:"key,omitempty"` // 访问令牌、用户ID或角色UUID
+	TextureType models.TextureType `json:"textureType,omitempty"`
+	Before      time.Time          `json:"before,omitzero"`
+}
+
+// fileSnapshot 表示快照文件的内容
+
+type fileSnapshot struct {
+	Seq uint64 `json:"seq"` // 快照包含的最后一条日志的序号
+	memorySnapshot
+}
+
+// NewFileStore 打开目录中的文件存储，目录不存在时自动创建
+// 打开时加载快照并重放其后的日志，日志末尾未写完的记录会被丢弃
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{
+		Dir:              dir,
+		CompactThreshold: DefaultCompactThreshold,
+		mem:              NewMemoryStore(),
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	journal, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.replayJournal(journal); err != nil {
+		journal.Close()
+		return nil, err
+	}
+	s.journal = journal
+
+	return s, nil
+}
+
+// loadSnapshot 加载快照
+func (s *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.Dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap fileSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	if err := s.mem.restore(snap.memorySnapshot); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	s.seq = snap.Seq
+	return nil
+}
+
+// replayJournal 重放快照之后的日志
+// 进程在写入日志时崩溃会留下不完整的最后一行，该记录未被确认，截断即可
+func (s *FileStore) replayJournal(journal *os.File) error {
+	reader := bufio.NewReader(journal)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			s.size = offset
+			if len(line) > 0 {
+				if err := journal.Truncate(offset); err != nil {
+					return err
+				}
+				return journal.Sync()
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil {
+			return fmt.Errorf("invalid journal entry at offset %d: %w", offset, err)
+		}
+		offset += int64(len(line))
+
+		// 跳过已包含在快照中的记录（压缩后、清空日志前崩溃时会出现）
+		if entry.Seq <= s.seq {
+			continue
+		}
+		if err := s.apply(entry); err != nil {
+			return fmt.Errorf("replay journal entry %d: %w", entry.Seq, err)
+		}
+		s.seq = entry.Seq
+		s.entries++
+	}
+}
+
+// apply 将修改应用到内存中
+func (s *FileStore) apply(entry journalEntry) error {
+	ctx := context.Background()
+	switch entry.Op {
+	case opCreateUser:
+		return s.mem.CreateUser(ctx, *entry.User)
+	case opUpdateUser:
+		return s.mem.UpdateUser(ctx, *entry.User)
+	case opCreateProfile:
+		return s.mem.CreateProfile(ctx, *entry.Profile)
+	case opCreateToken:
+		return s.mem.CreateToken(ctx, *entry.Token)
+	case opDeleteToken:
+		return s.mem.DeleteToken(ctx, entry.Key)
+	case opDeleteUserTokens:
+		return s.mem.DeleteUserTokens(ctx, entry.Key)
+	case opDeleteTokensBefore:
+		return s.mem.DeleteTokensBefore(ctx, entry.Before)
+	case opSetProfileTexture:
+		return s.mem.SetProfileTexture(ctx, *entry.Texture)
+	case opDeleteProfileTexture:
+		return s.mem.DeleteProfileTexture(ctx, entry.Key, entry.TextureType)
+	default:
+		return fmt.Errorf("unknown journal operation %q", entry.Op)
+	}
+}
+
+// commit 写入日志并在同步到磁盘后应用修改，修改无法应用或写入失败时内存中的数据不变
+func (s *FileStore) commit(entry journalEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return os.ErrClosed
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+	// 所有修改都持有s.mu，检查通过后应用修改一定成功
+	if err := s.check(entry); err != nil {
+		return err
+	}
+
+	entry.Seq = s.seq + 1
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := s.appendJournal(append(line, '\n')); err != nil {
+		return err
+	}
+	s.seq = entry.Seq
+	s.entries++
+
+	if err := s.apply(entry); err != nil {
+		s.failed = fmt.Errorf("journal entry %d is out of sync with memory: %w", entry.Seq, err)
+		return s.failed
+	}
+
+	// 修改已经写入磁盘，压缩失败不影响本次修改，等待下一批日志写入后重试
+	if s.CompactThreshold > 0 && s.entries >= s.CompactThreshold {
+		if err := s.compact(); err != nil {
+			log.Printf("压缩文件存储失败: %v\n", err)
+			s.entries = 0
+		}
+	}
+	return nil
+}
+
+// check 检查修改能否应用到内存中，不修改数据
+func (s *FileStore) check(entry journalEntry) error {
+	m := s.mem
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	switch entry.Op {
+	case opCreateUser:
+		if _, exists := m.users[strings.ToLower(entry.User.Username)]; exists {
+			return ErrConflict
+		}
+	case opUpdateUser:
+		if _, exists := m.users[strings.ToLower(entry.User.Username)]; !exists {
+			return ErrNotFound
+		}
+	case opCreateProfile:
+		if _, exists := m.profiles[entry.Profile.ID]; exists {
+			return ErrConflict
+		}
+		if _, exists := m.profileNames[strings.ToLower(entry.Profile.Name)]; exists {
+			return ErrConflict
+		}
+	case opDeleteToken:
+		if _, exists := m.tokens[entry.Key]; !exists {
+			return ErrNotFound
+		}
+	case opDeleteProfileTexture:
+		if _, exists := m.textures[entry.Key][entry.TextureType]; !exists {
+			return ErrNotFound
+		}
+	case opCreateToken, opDeleteUserTokens, opDeleteTokensBefore, opSetProfileTexture:
+	default:
+		return fmt.Errorf("unknown journal operation %q", entry.Op)
+	}
+	return nil
+}
+
+// appendJournal 在已确认的记录之后写入一条记录并同步到磁盘
+// 写入失败时截断未写完的内容，避免之后的记录与其拼接成无法重放的一行；截断也失败时拒绝之后的所有修改
+func (s *FileStore) appendJournal(line []byte) error {
+	_, err := s.journal.WriteAt(line, s.size)
+	if err == nil {
+		err = s.journal.Sync()
+	}
+	if err != nil {
+		if truncErr := s.journal.Truncate(s.size); truncErr != nil {
+			s.failed = fmt.Errorf("file store failed: %w", errors.Join(err, truncErr))
+			return s.failed
+		}
+		return err
+	}
+	s.size += int64(len(line))
+	return nil
+}
+
+// Compact 将当前数据写入快照并清空日志
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return os.ErrClosed
+	}
+	return s.compact()
+}
+
+// compact 将当前数据写入快照并清空日志，调用时需持有锁
+func (s *FileStore) compact() error {
+	if s.failed != nil {
+		return s.failed
+	}
+
+	data, err := json.Marshal(fileSnapshot{
+		Seq:            s.seq,
+		memorySnapshot: s.mem.snapshot(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.Dir, snapshotFileName), data); err != nil {
+		return err
+	}
+
+	// 快照已包含全部日志，在此之前崩溃时重放日志会跳过快照中已有的记录
+	if err := s.journal.Truncate(0); err != nil {
+		return err
+	}
+	s.size = 0
+	if err := s.journal.Sync(); err != nil {
+		return err
+	}
+	s.entries = 0
+	return nil
+}
+
+// Close 压缩日志并关闭存储
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return nil
+	}
+	err := s.compact()
+	if closeErr := s.journal.Close(); err == nil {
+		err = closeErr
+	}
+	s.journal = nil
+	return err
+}
+
+// writeFileAtomic 原子地写入文件：先写入同目录下的临时文件并同步到磁盘，再重命名为目标文件
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// 同步目录，确保重命名本身已写入磁盘（部分平台不支持同步目录，忽略其错误）
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// CreateUser 实现创建用户
+func (s *FileStore) CreateUser(ctx context.Context, user UserCredentials) error {
+	return s.commit(journalEntry{Op: opCreateUser, User: &user})
+}
+
+// GetUser 实现根据用户名查询用户
+func (s *FileStore) GetUser(ctx context.Context, username string) (*UserCredentials, error) {
+	return s.mem.GetUser(ctx, username)
+}
+
+// UpdateUser 实现更新用户的凭证
+func (s *FileStore) UpdateUser(ctx context.Context, user UserCredentials) error {
+	return s.commit(journalEntry{Op: opUpdateUser, User: &user})
+}
+
+// CreateProfile 实现创建角色
+func (s *FileStore) CreateProfile(ctx context.Context, profile ProfileInfo) error {
+	return s.commit(journalEntry{Op: opCreateProfile, Profile: &profile})
+}
+
+// GetProfile 实现根据UUID查询角色
+func (s *FileStore) GetProfile(ctx context.Context, id string) (*ProfileInfo, error) {
+	return s.mem.GetProfile(ctx, id)
+}
+
+// GetProfileByName 实现根据名称查询角色
+func (s *FileStore) GetProfileByName(ctx context.Context, name string) (*ProfileInfo, error) {
+	return s.mem.GetProfileByName(ctx, name)
+}
+
+// ListProfiles 实现返回用户的全部角色
+func (s *FileStore) ListProfiles(ctx context.Context, userID string) ([]ProfileInfo, error) {
+	return s.mem.ListProfiles(ctx, userID)
+}
+
+// CreateToken 实现保存访问令牌
+func (s *FileStore) CreateToken(ctx context.Context, token AccessTokenInfo) error {
+	return s.commit(journalEntry{Op: opCreateToken, Token: &token})
+}
+
+// GetToken 实现查询访问令牌
+func (s *FileStore) GetToken(ctx context.Context, accessToken string) (*AccessTokenInfo, error) {
+	return s.mem.GetToken(ctx, accessToken)
+}
+
+// ListTokens 实现返回用户的全部访问令牌
+func (s *FileStore) ListTokens(ctx context.Context, userID string) ([]AccessTokenInfo, error) {
+	return s.mem.ListTokens(ctx, userID)
+}
+
+// DeleteToken 实现删除访问令牌
+func (s *FileStore) DeleteToken(ctx context.Context, accessToken string) error {
+	return s.commit(journalEntry{Op: opDeleteToken, Key: accessToken})
+}
+
+// DeleteUserTokens 实现删除用户的全部访问令牌
+func (s *FileStore) DeleteUserTokens(ctx context.Context, userID string) error {
+	return s.commit(journalEntry{Op: opDeleteUserTokens, Key: userID})
+}
+
+// DeleteTokensBefore 实现删除签发时间早于before的访问令牌
+// 每次认证都会调用，没有需要删除的令牌时不写入日志
+func (s *FileStore) DeleteTokensBefore(ctx context.Context, before time.Time) error {
+	if !s.mem.hasTokensBefore(before) {
+		return nil
+	}
+	return s.commit(journalEntry{Op: opDeleteTokensBefore, Before: before})
+}
+
+// PutJoinRecord 实现保存进入服务器记录
+func (s *FileStore) PutJoinRecord(ctx context.Context, record JoinRecord) error {
+	return s.mem.PutJoinRecord(ctx, record)
+}
+
+// GetJoinRecord 实现根据serverId查询进入服务器记录
+func (s *FileStore) GetJoinRecord(ctx context.Context, serverID string) (*JoinRecord, error) {
+	return s.mem.GetJoinRecord(ctx, serverID)
+}
+
+// DeleteJoinRecordsBefore 实现删除创建时间早于before的进入服务器记录
+func (s *FileStore) DeleteJoinRecordsBefore(ctx context.Context, before time.Time) error {
+	return s.mem.DeleteJoinRecordsBefore(ctx, before)
+}
+
+// SetProfileTexture 实现设置角色的材质
+func (s *FileStore) SetProfileTexture(ctx context.Context, texture ProfileTexture) error {
+	return s.commit(journalEntry{Op: opSetProfileTexture, Texture: &texture})
+}
+
+// GetProfileTextures 实现返回角色的全部材质
+func (s *FileStore) GetProfileTextures(ctx context.Context, profileID string) ([]ProfileTexture, error) {
+	return s.mem.GetProfileTextures(ctx, profileID)
+}
+
+// DeleteProfileTexture 实现删除角色的材质
+func (s *FileStore) DeleteProfileTexture(ctx context.Context, profileID string, textureType models.TextureType) error {
+	return s.commit(journalEntry{Op: opDeleteProfileTexture, Key: profileID, TextureType: textureType})
+}
+
+// TextureInUse 实现检查材质是否仍被任何角色使用
+func (s *FileStore) TextureInUse(ctx context.Context, hash string) (bool, error) {
+	return s.mem.TextureInUse(ctx, hash)
+}
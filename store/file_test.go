@@ -0,0 +1,279 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// openFileStore 打开文件存储，测试结束时关闭
+func openFileStore(t *testing.T, dir string) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// mustCreateUser 创建用户
+func mustCreateUser(t *testing.T, s Store, username string) {
+	t.Helper()
+	if err := s.CreateUser(context.Background(), UserCredentials{ID: "id-" + username, Username: username, Password: "hash"}); err != nil {
+		t.Fatalf("CreateUser(%q): %v", username, err)
+	}
+}
+
+// assertUser 检查用户是否存在
+func assertUser(t *testing.T, s Store, username string, want bool) {
+	t.Helper()
+	_, err := s.GetUser(context.Background(), username)
+	switch {
+	case want && err != nil:
+		t.Fatalf("GetUser(%q): %v", username, err)
+	case !want && !errors.Is(err, ErrNotFound):
+		t.Fatalf("GetUser(%q) = %v, want ErrNotFound", username, err)
+	}
+}
+
+// appendToJournal 在日志末尾追加内容，模拟写入到一半时崩溃
+func appendToJournal(t *testing.T, dir, data string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileStoreReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	s.CompactThreshold = 0
+
+	mustCreateUser(t, s, "alice")
+	if err := s.UpdateUser(ctx, UserCredentials{ID: "id-alice", Username: "alice", Password: "rehashed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateProfile(ctx, ProfileInfo{ID: "p1", UserID: "id-alice", Name: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, accessToken := range []string{"t1", "t2", "t3"} {
+		token := AccessTokenInfo{AccessToken: accessToken, UserID: "id-alice", CreatedAt: now.Add(time.Duration(i) * time.Minute)}
+		if err := s.CreateToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.DeleteToken(ctx, "t2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTokensBefore(ctx, now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetProfileTexture(ctx, ProfileTexture{ProfileID: "p1", Type: models.TextureSkin, Hash: "h1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不关闭存储直接重新打开，模拟进程崩溃
+	reopened := openFileStore(t, dir)
+	user, err := reopened.GetUser(ctx, "alice")
+	if err != nil || user.Password != "rehashed" {
+		t.Fatalf("GetUser = %+v, %v", user, err)
+	}
+	if _, err := reopened.GetProfileByName(ctx, "alice"); err != nil {
+		t.Fatalf("GetProfileByName: %v", err)
+	}
+	tokens, err := reopened.ListTokens(ctx, "id-alice")
+	if err != nil || len(tokens) != 1 || tokens[0].AccessToken != "t3" {
+		t.Fatalf("ListTokens = %+v, %v", tokens, err)
+	}
+	if inUse, _ := reopened.TextureInUse(ctx, "h1"); !inUse {
+		t.Fatal("texture h1 not restored")
+	}
+}
+
+func TestFileStoreTruncatesTornLastLine(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	mustCreateUser(t, s, "alice")
+	appendToJournal(t, dir, `{"seq":2,"op":"createUser","user":{"ID":"id-bob","Usern`)
+
+	reopened := openFileStore(t, dir)
+	assertUser(t, reopened, "alice", true)
+	assertUser(t, reopened, "bob", false)
+
+	// 截断后写入的记录可以正常重放
+	mustCreateUser(t, reopened, "carol")
+	assertUser(t, openFileStore(t, dir), "carol", true)
+}
+
+func TestFileStoreOverwritesUnconfirmedTail(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	mustCreateUser(t, s, "alice")
+
+	// 已确认的记录之后残留未写完的内容时，新记录不能与其拼接
+	appendToJournal(t, dir, `{"seq":2,"op":"createUser","user":{"ID":"id-bob","Usern`)
+	mustCreateUser(t, s, "carol")
+
+	reopened := openFileStore(t, dir)
+	assertUser(t, reopened, "alice", true)
+	assertUser(t, reopened, "carol", true)
+}
+
+func TestFileStoreReopenAfterCompact(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	mustCreateUser(t, s, "alice")
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, journalFileName)); err != nil || info.Size() != 0 {
+		t.Fatalf("journal after Compact: %v, %v", info, err)
+	}
+	mustCreateUser(t, s, "bob")
+
+	reopened := openFileStore(t, dir)
+	assertUser(t, reopened, "alice", true)
+	assertUser(t, reopened, "bob", true)
+}
+
+func TestFileStoreRejectedChangeNotJournaled(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	mustCreateUser(t, s, "alice")
+	if err := s.CreateUser(context.Background(), UserCredentials{ID: "other", Username: "alice"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("CreateUser duplicate = %v, want ErrConflict", err)
+	}
+	if err := s.DeleteToken(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteToken missing = %v, want ErrNotFound", err)
+	}
+
+	reopened := openFileStore(t, dir)
+	user, err := reopened.GetUser(context.Background(), "alice")
+	if err != nil || user.ID != "id-alice" {
+		t.Fatalf("GetUser = %+v, %v", user, err)
+	}
+}
+
+func TestFileStoreWriteFailureLeavesMemoryUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	mustCreateUser(t, s, "alice")
+
+	// 关闭日志文件使写入失败
+	s.journal.Close()
+	if err := s.CreateUser(context.Background(), UserCredentials{ID: "id-bob", Username: "bob"}); err == nil {
+		t.Fatal("CreateUser succeeded with a broken journal")
+	}
+	assertUser(t, s, "bob", false)
+
+	reopened := openFileStore(t, dir)
+	assertUser(t, reopened, "alice", true)
+	assertUser(t, reopened, "bob", false)
+}
+
+func TestFileStoreCompactFailureKeepsWrite(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	s.CompactThreshold = 1
+
+	// 快照所在的目录不存在，压缩失败
+	s.Dir = filepath.Join(dir, "missing")
+	mustCreateUser(t, s, "alice")
+	mustCreateUser(t, s, "bob")
+	s.Dir = dir
+
+	reopened := openFileStore(t, dir)
+	assertUser(t, reopened, "alice", true)
+	assertUser(t, reopened, "bob", true)
+}
+
+// crashDirEnv 强制结束测试中子进程使用的数据目录
+const crashDirEnv = "FILESTORE_CRASH_DIR"
+
+func TestFileStoreSurvivesKill(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a child process")
+	}
+	dir := t.TempDir()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFileStoreCrashHelper$")
+	cmd.Env = append(os.Environ(), crashDirEnv+"="+dir)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 子进程每确认一次修改输出一行，确认一定数量后强制结束
+	acked := -1
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && acked < 200 {
+		if n, err := strconv.Atoi(scanner.Text()); err == nil {
+			acked = n
+		}
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	if acked < 0 {
+		t.Fatal("child process confirmed no writes")
+	}
+
+	reopened := openFileStore(t, dir)
+	for i := 0; i <= acked; i++ {
+		assertUser(t, reopened, fmt.Sprintf("user%d", i), true)
+	}
+}
+
+// TestFileStoreCrashHelper 在子进程中不断写入，直到被强制结束
+func TestFileStoreCrashHelper(t *testing.T) {
+	dir := os.Getenv(crashDirEnv)
+	if dir == "" {
+		t.Skip("only runs as the child process of TestFileStoreSurvivesKill")
+	}
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 较小的阈值使强制结束可能发生在压缩过程中
+	s.CompactThreshold = 16
+	for i := 0; ; i++ {
+		if err := s.CreateUser(context.Background(), UserCredentials{ID: strconv.Itoa(i), Username: fmt.Sprintf("user%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Println(i)
+	}
+}
+
+func TestFileStoreUsernameCaseInsensitive(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	mustCreateUser(t, s, "Alice@example.com")
+	if err := s.CreateUser(context.Background(), UserCredentials{ID: "other", Username: "alice@EXAMPLE.com"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("CreateUser differing only in case = %v, want ErrConflict", err)
+	}
+
+	reopened := openFileStore(t, dir)
+	user, err := reopened.GetUser(context.Background(), "ALICE@example.com")
+	if err != nil || user.Username != "Alice@example.com" {
+		t.Fatalf("GetUser = %+v, %v", user, err)
+	}
+}
@@ -0,0 +1,374 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// MemoryStore 是Store的内存实现
+// 用于演示和测试，重启后数据会丢失
+
+type MemoryStore struct {
+	users        map[string]UserCredentials                       // 用户名（小写） -> 用户凭证
+	profiles     map[string]ProfileInfo                           // 角色UUID -> 角色
+	profileNames map[string]string                                // 角色名称（小写） -> 角色UUID
+	userProfiles map[string][]string                              // 用户ID -> 角色UUID列表（按创建顺序）
+	tokens       map[string]AccessTokenInfo                       // 访问令牌 -> 令牌信息
+	joinRecords  map[string]JoinRecord                            // serverId -> 进入记录
+	textures     map[string]map[models.TextureType]ProfileTexture // 角色UUID -> 材质类型 -> 材质
+
+	mu sync.RWMutex
+}
+
+// NewMemoryStore 创建一个新的内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:        make(map[string]UserCredentials),
+		profiles:     make(map[string]ProfileInfo),
+		profileNames: make(map[string]string),
+		userProfiles: make(map[string][]string),
+		tokens:       make(map[string]AccessTokenInfo),
+		joinRecords:  make(map[string]JoinRecord),
+		textures:     make(map[string]map[models.TextureType]ProfileTexture),
+	}
+}
+
+// CreateUser 实现创建用户
+func (s *MemoryStore) CreateUser(ctx context.Context, user UserCredentials) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	username := strings.ToLower(user.Username)
+	if _, exists := s.users[username]; exists {
+		return ErrConflict
+	}
+	s.users[username] = user
+	return nil
+}
+
+// GetUser 实现根据用户名查询用户
+func (s *MemoryStore) GetUser(ctx context.Context, username string) (*UserCredentials, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[strings.ToLower(username)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// UpdateUser 实现更新用户的凭证
+func (s *MemoryStore) UpdateUser(ctx context.Context, user UserCredentials) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	username := strings.ToLower(user.Username)
+	if _, exists := s.users[username]; !exists {
+		return ErrNotFound
+	}
+	s.users[username] = user
+	return nil
+}
+
+// CreateProfile 实现创建角色
+func (s *MemoryStore) CreateProfile(ctx context.Context, profile ProfileInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToLower(profile.Name)
+	if _, exists := s.profiles[profile.ID]; exists {
+		return ErrConflict
+	}
+	if _, exists := s.profileNames[name]; exists {
+		return ErrConflict
+	}
+	s.profiles[profile.ID] = profile
+	s.profileNames[name] = profile.ID
+	s.userProfiles[profile.UserID] = append(s.userProfiles[profile.UserID], profile.ID)
+	return nil
+}
+
+// GetProfile 实现根据UUID查询角色
+func (s *MemoryStore) GetProfile(ctx context.Context, id string) (*ProfileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	profile, exists := s.profiles[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &profile, nil
+}
+
+// GetProfileByName 实现根据名称查询角色
+func (s *MemoryStore) GetProfileByName(ctx context.Context, name string) (*ProfileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.profileNames[strings.ToLower(name)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	profile := s.profiles[id]
+	return &profile, nil
+}
+
+// ListProfiles 实现返回用户的全部角色
+func (s *MemoryStore) ListProfiles(ctx context.Context, userID string) ([]ProfileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	profiles := make([]ProfileInfo, 0, len(s.userProfiles[userID]))
+	for _, id := range s.userProfiles[userID] {
+		profiles = append(profiles, s.profiles[id])
+	}
+	return profiles, nil
+}
+
+// CreateToken 实现保存访问令牌
+func (s *MemoryStore) CreateToken(ctx context.Context, token AccessTokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.AccessToken] = token
+	return nil
+}
+
+// GetToken 实现查询访问令牌
+func (s *MemoryStore) GetToken(ctx context.Context, accessToken string) (*AccessTokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, exists := s.tokens[accessToken]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+// ListTokens 实现返回用户的全部访问令牌
+func (s *MemoryStore) ListTokens(ctx context.Context, userID string) ([]AccessTokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tokens []AccessTokenInfo
+	for _, token := range s.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// DeleteToken 实现删除访问令牌
+func (s *MemoryStore) DeleteToken(ctx context.Context, accessToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tokens[accessToken]; !exists {
+		return ErrNotFound
+	}
+	delete(s.tokens, accessToken)
+	return nil
+}
+
+// DeleteUserTokens 实现删除用户的全部访问令牌
+func (s *MemoryStore) DeleteUserTokens(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for accessToken, token := range s.tokens {
+		if token.UserID == userID {
+			delete(s.tokens, accessToken)
+		}
+	}
+	return nil
+}
+
+// DeleteTokensBefore 实现删除签发时间早于before的访问令牌
+func (s *MemoryStore) DeleteTokensBefore(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for accessToken, token := range s.tokens {
+		if token.CreatedAt.Before(before) {
+			delete(s.tokens, accessToken)
+		}
+	}
+	return nil
+}
+
+// PutJoinRecord 实现保存进入服务器记录
+func (s *MemoryStore) PutJoinRecord(ctx context.Context, record JoinRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.joinRecords[record.ServerID] = record
+	return nil
+}
+
+// GetJoinRecord 实现根据serverId查询进入服务器记录
+func (s *MemoryStore) GetJoinRecord(ctx context.Context, serverID string) (*JoinRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.joinRecords[serverID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+// DeleteJoinRecordsBefore 实现删除创建时间早于before的进入服务器记录
+func (s *MemoryStore) DeleteJoinRecordsBefore(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for serverID, record := range s.joinRecords {
+		if record.CreatedAt.Before(before) {
+			delete(s.joinRecords, serverID)
+		}
+	}
+	return nil
+}
+
+// SetProfileTexture 实现设置角色的材质
+func (s *MemoryStore) SetProfileTexture(ctx context.Context, texture ProfileTexture) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.textures[texture.ProfileID] == nil {
+		s.textures[texture.ProfileID] = make(map[models.TextureType]ProfileTexture)
+	}
+	s.textures[texture.ProfileID][texture.Type] = texture
+	return nil
+}
+
+// GetProfileTextures 实现返回角色的全部材质
+func (s *MemoryStore) GetProfileTextures(ctx context.Context, profileID string) ([]ProfileTexture, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	textures := make([]ProfileTexture, 0, len(s.textures[profileID]))
+	for _, texture := range s.textures[profileID] {
+		textures = append(textures, texture)
+	}
+	return textures, nil
+}
+
+// DeleteProfileTexture 实现删除角色的材质
+func (s *MemoryStore) DeleteProfileTexture(ctx context.Context, profileID string, textureType models.TextureType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.textures[profileID][textureType]; !exists {
+		return ErrNotFound
+	}
+	delete(s.textures[profileID], textureType)
+	if len(s.textures[profileID]) == 0 {
+		delete(s.textures, profileID)
+	}
+	return nil
+}
+
+// TextureInUse 实现检查材质是否仍被任何角色使用
+func (s *MemoryStore) TextureInUse(ctx context.Context, hash string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, profileTextures := range s.textures {
+		for _, texture := range profileTextures {
+			if texture.Hash == hash {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// memorySnapshot 表示MemoryStore中需要持久化的数据，进入服务器记录的有效期很短，不包含在内
+
+type memorySnapshot struct {
+	Users    []UserCredentials `json:"users"`
+	Profiles []ProfileInfo     `json:"profiles"` // 同一用户的角色按创建顺序排列
+	Tokens   []AccessTokenInfo `json:"tokens"`
+	Textures []ProfileTexture  `json:"textures"`
+}
+
+// snapshot 导出存储中的数据
+func (s *MemoryStore) snapshot() memorySnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := memorySnapshot{
+		Users:    make([]UserCredentials, 0, len(s.users)),
+		Profiles: make([]ProfileInfo, 0, len(s.profiles)),
+		Tokens:   make([]AccessTokenInfo, 0, len(s.tokens)),
+		Textures: make([]ProfileTexture, 0, len(s.textures)),
+	}
+	for _, user := range s.users {
+		snap.Users = append(snap.Users, user)
+	}
+	for _, ids := range s.userProfiles {
+		for _, id := range ids {
+			snap.Profiles = append(snap.Profiles, s.profiles[id])
+		}
+	}
+	for _, token := range s.tokens {
+		snap.Tokens = append(snap.Tokens, token)
+	}
+	for _, profileTextures := range s.textures {
+		for _, texture := range profileTextures {
+			snap.Textures = append(snap.Textures, texture)
+		}
+	}
+	return snap
+}
+
+// restore 导入snapshot导出的数据
+func (s *MemoryStore) restore(snap memorySnapshot) error {
+	ctx := context.Background()
+	for _, user := range snap.Users {
+		if err := s.CreateUser(ctx, user); err != nil {
+			return err
+		}
+	}
+	for _, profile := range snap.Profiles {
+		if err := s.CreateProfile(ctx, profile); err != nil {
+			return err
+		}
+	}
+	for _, token := range snap.Tokens {
+		if err := s.CreateToken(ctx, token); err != nil {
+			return err
+		}
+	}
+	for _, texture := range snap.Textures {
+		if err := s.SetProfileTexture(ctx, texture); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasTokensBefore 检查是否存在签发时间早于before的访问令牌
+func (s *MemoryStore) hasTokensBefore(before time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.CreatedAt.Before(before) {
+			return true
+		}
+	}
+	return false
+}
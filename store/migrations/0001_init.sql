@@ -0,0 +1,52 @@
+-- 初始表结构
+-- 语句之间以分号分隔，只使用SQLite、MySQL和PostgreSQL通用的语法
+-- 时间均为Unix时间戳（纳秒）
+
+-- 用户名不区分大小写且不能重复，与角色名称相同，不依赖数据库的排序规则
+CREATE TABLE users (
+	id             VARCHAR(32)  NOT NULL PRIMARY KEY,
+	username       VARCHAR(255) NOT NULL,
+	username_lower VARCHAR(255) NOT NULL UNIQUE,
+	password       VARCHAR(255) NOT NULL
+);
+
+-- 角色名称不区分大小写且不能重复，name_lower保存小写的名称用于唯一性约束和查询
+CREATE TABLE profiles (
+	id         VARCHAR(32) NOT NULL PRIMARY KEY,
+	user_id    VARCHAR(32) NOT NULL,
+	name       VARCHAR(64) NOT NULL,
+	name_lower VARCHAR(64) NOT NULL UNIQUE,
+	created_at BIGINT      NOT NULL
+);
+
+CREATE INDEX idx_profiles_user_id ON profiles (user_id);
+
+-- client_token和join_records的server_id由客户端提供，服务层限制其长度不超过255个字符
+CREATE TABLE access_tokens (
+	access_token VARCHAR(64)  NOT NULL PRIMARY KEY,
+	client_token VARCHAR(255) NOT NULL,
+	user_id      VARCHAR(32)  NOT NULL,
+	profile_id   VARCHAR(32)  NOT NULL,
+	created_at   BIGINT       NOT NULL
+);
+
+CREATE INDEX idx_access_tokens_user_id ON access_tokens (user_id);
+
+CREATE INDEX idx_access_tokens_created_at ON access_tokens (created_at);
+
+CREATE TABLE join_records (
+	server_id  VARCHAR(255) NOT NULL PRIMARY KEY,
+	profile_id VARCHAR(32)  NOT NULL,
+	ip         VARCHAR(64)  NOT NULL,
+	created_at BIGINT       NOT NULL
+);
+
+CREATE TABLE profile_textures (
+	profile_id   VARCHAR(32) NOT NULL,
+	texture_type VARCHAR(16) NOT NULL,
+	hash         VARCHAR(64) NOT NULL,
+	model        VARCHAR(16) NOT NULL,
+	PRIMARY KEY (profile_id, texture_type)
+);
+
+CREATE INDEX idx_profile_textures_hash ON profile_textures (hash);
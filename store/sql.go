@@ -0,0 +1,458 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// Dialect 表示SQL方言，决定查询中占位符的格式
+
+type Dialect int
+
+const (
+	DialectSQLite   Dialect = iota // SQLite，占位符为?
+	DialectMySQL                   // MySQL，占位符为?
+	DialectPostgres                // PostgreSQL，占位符为$1、$2……
+)
+
+// rebind 将查询中的?占位符转换为方言的格式
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// migrations 按版本号排序的表结构迁移脚本，文件名格式为 版本号_描述.sql
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// SQLStore 是Store的database/sql实现，支持SQLite、MySQL和PostgreSQL
+// 不依赖具体的数据库驱动，由调用者导入驱动并打开数据库
+// 使用前需要调用Migrate创建或升级表结构
+
+type SQLStore struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// NewSQLStore 创建一个基于数据库的存储
+func NewSQLStore(db *sql.DB, dialect Dialect) *SQLStore {
+	return &SQLStore{
+		DB:      db,
+		Dialect: dialect,
+	}
+}
+
+// Migrate 按版本号依次执行尚未执行的迁移脚本，已执行的版本记录在schema_migrations表中
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	return s.migrateFS(ctx, migrations)
+}
+
+// migration 表示一个迁移脚本
+
+type migration struct {
+	name    string
+	version int64
+}
+
+// migrateFS 执行fsys中migrations目录下尚未执行的迁移脚本
+func (s *SQLStore) migrateFS(ctx context.Context, fsys fs.FS) error {
+	if _, err := s.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, applied_at BIGINT NOT NULL)"); err != nil {
+		return err
+	}
+
+	applied := make(map[int64]bool)
+	rows, err := s.DB.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	var pending []migration
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		version, err := strconv.ParseInt(strings.SplitN(base, "_", 2)[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid migration name %q", base)
+		}
+		if !applied[version] {
+			pending = append(pending, migration{name: name, version: version})
+		}
+	}
+
+	// 按版本号而不是文件名排序，版本号的位数不同时也能按顺序执行
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].version < pending[j].version
+	})
+	for _, m := range pending {
+		if err := s.migrate(ctx, fsys, m); err != nil {
+			return fmt.Errorf("migration %s: %w", strings.TrimPrefix(m.name, "migrations/"), err)
+		}
+	}
+	return nil
+}
+
+// migrate 在事务中执行一个迁移脚本
+// 注意MySQL中的DDL语句会隐式提交事务，迁移失败时可能需要手动清理
+func (s *SQLStore) migrate(ctx context.Context, fsys fs.FS, m migration) error {
+	script, err := fs.ReadFile(fsys, m.name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 部分驱动不支持一次执行多条语句，按分号拆分后逐条执行
+	for _, statement := range splitStatements(string(script)) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.Dialect.rebind("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)"), m.version, time.Now().UnixNano()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements 去除注释行并按分号拆分SQL脚本
+func splitStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// exec 执行语句，返回受影响的行数
+func (s *SQLStore) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	result, err := s.DB.ExecContext(ctx, s.Dialect.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// exists 检查查询是否有结果
+func (s *SQLStore) exists(ctx context.Context, query string, args ...any) (bool, error) {
+	var one int
+	err := s.DB.QueryRowContext(ctx, s.Dialect.rebind(query), args...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// insert 执行插入语句，插入失败且conflictQuery有结果时返回ErrConflict
+// 各驱动的唯一性约束错误类型不同，因此通过再次查询判断是否为冲突
+func (s *SQLStore) insert(ctx context.Context, conflictQuery string, conflictArgs []any, query string, args ...any) error {
+	_, err := s.exec(ctx, query, args...)
+	if err == nil {
+		return nil
+	}
+	if conflict, _ := s.exists(ctx, conflictQuery, conflictArgs...); conflict {
+		return ErrConflict
+	}
+	return err
+}
+
+// notFound 将sql.ErrNoRows转换为ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// CreateUser 实现创建用户
+func (s *SQLStore) CreateUser(ctx context.Context, user UserCredentials) error {
+	usernameLower := strings.ToLower(user.Username)
+	return s.insert(ctx,
+		"SELECT 1 FROM users WHERE username_lower = ?", []any{usernameLower},
+		"INSERT INTO users (id, username, username_lower, password) VALUES (?, ?, ?, ?)", user.ID, user.Username, usernameLower, user.Password)
+}
+
+// GetUser 实现根据用户名查询用户
+func (s *SQLStore) GetUser(ctx context.Context, username string) (*UserCredentials, error) {
+	var user UserCredentials
+	err := s.DB.QueryRowContext(ctx, s.Dialect.rebind("SELECT id, username, password FROM users WHERE username_lower = ?"), strings.ToLower(username)).
+		Scan(&user.ID, &user.Username, &user.Password)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+// UpdateUser 实现更新用户的凭证
+func (s *SQLStore) UpdateUser(ctx context.Context, user UserCredentials) error {
+	n, err := s.exec(ctx, "UPDATE users SET password = ? WHERE username_lower = ?", user.Password, strings.ToLower(user.Username))
+	if err != nil || n > 0 {
+		return err
+	}
+
+	// MySQL在值未改变时返回0行，需要再次查询用户是否存在
+	exists, err := s.exists(ctx, "SELECT 1 FROM users WHERE username_lower = ?", strings.ToLower(user.Username))
+	if err == nil && !exists {
+		return ErrNotFound
+	}
+	return err
+}
+
+// CreateProfile 实现创建角色
+func (s *SQLStore) CreateProfile(ctx context.Context, profile ProfileInfo) error {
+	nameLower := strings.ToLower(profile.Name)
+	return s.insert(ctx,
+		"SELECT 1 FROM profiles WHERE id = ? OR name_lower = ?", []any{profile.ID, nameLower},
+		"INSERT INTO profiles (id, user_id, name, name_lower, created_at) VALUES (?, ?, ?, ?, ?)",
+		profile.ID, profile.UserID, profile.Name, nameLower, profile.CreatedAt.UnixNano())
+}
+
+// scanProfile 读取一行角色数据
+func scanProfile(row interface{ Scan(...any) error }) (*ProfileInfo, error) {
+	var profile ProfileInfo
+	var createdAt int64
+	if err := row.Scan(&profile.ID, &profile.UserID, &profile.Name, &createdAt); err != nil {
+		return nil, err
+	}
+	profile.CreatedAt = time.Unix(0, createdAt)
+	return &profile, nil
+}
+
+// GetProfile 实现根据UUID查询角色
+func (s *SQLStore) GetProfile(ctx context.Context, id string) (*ProfileInfo, error) {
+	profile, err := scanProfile(s.DB.QueryRowContext(ctx, s.Dialect.rebind("SELECT id, user_id, name, created_at FROM profiles WHERE id = ?"), id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return profile, nil
+}
+
+// GetProfileByName 实现根据名称查询角色
+func (s *SQLStore) GetProfileByName(ctx context.Context, name string) (*ProfileInfo, error) {
+	profile, err := scanProfile(s.DB.QueryRowContext(ctx, s.Dialect.rebind("SELECT id, user_id, name, created_at FROM profiles WHERE name_lower = ?"), strings.ToLower(name)))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return profile, nil
+}
+
+// ListProfiles 实现返回用户的全部角色
+func (s *SQLStore) ListProfiles(ctx context.Context, userID string) ([]ProfileInfo, error) {
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.rebind("SELECT id, user_id, name, created_at FROM profiles WHERE user_id = ? ORDER BY created_at, id"), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []ProfileInfo
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *profile)
+	}
+	return profiles, rows.Err()
+}
+
+// CreateToken 实现保存访问令牌
+func (s *SQLStore) CreateToken(ctx context.Context, token AccessTokenInfo) error {
+	_, err := s.exec(ctx, "INSERT INTO access_tokens (access_token, client_token, user_id, profile_id, created_at) VALUES (?, ?, ?, ?, ?)",
+		token.AccessToken, token.ClientToken, token.UserID, token.ProfileID, token.CreatedAt.UnixNano())
+	return err
+}
+
+// scanToken 读取一行访问令牌数据
+func scanToken(row interface{ Scan(...any) error }) (*AccessTokenInfo, error) {
+	var token AccessTokenInfo
+	var createdAt int64
+	if err := row.Scan(&token.AccessToken, &token.ClientToken, &token.UserID, &token.ProfileID, &createdAt); err != nil {
+		return nil, err
+	}
+	token.CreatedAt = time.Unix(0, createdAt)
+	return &token, nil
+}
+
+// GetToken 实现查询访问令牌
+func (s *SQLStore) GetToken(ctx context.Context, accessToken string) (*AccessTokenInfo, error) {
+	token, err := scanToken(s.DB.QueryRowContext(ctx, s.Dialect.rebind("SELECT access_token, client_token, user_id, profile_id, created_at FROM access_tokens WHERE access_token = ?"), accessToken))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return token, nil
+}
+
+// ListTokens 实现返回用户的全部访问令牌
+func (s *SQLStore) ListTokens(ctx context.Context, userID string) ([]AccessTokenInfo, error) {
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.rebind("SELECT access_token, client_token, user_id, profile_id, created_at FROM access_tokens WHERE user_id = ? ORDER BY created_at"), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []AccessTokenInfo
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteToken 实现删除访问令牌
+func (s *SQLStore) DeleteToken(ctx context.Context, accessToken string) error {
+	n, err := s.exec(ctx, "DELETE FROM access_tokens WHERE access_token = ?", accessToken)
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeleteUserTokens 实现删除用户的全部访问令牌
+func (s *SQLStore) DeleteUserTokens(ctx context.Context, userID string) error {
+	_, err := s.exec(ctx, "DELETE FROM access_tokens WHERE user_id = ?", userID)
+	return err
+}
+
+// DeleteTokensBefore 实现删除签发时间早于before的访问令牌
+func (s *SQLStore) DeleteTokensBefore(ctx context.Context, before time.Time) error {
+	_, err := s.exec(ctx, "DELETE FROM access_tokens WHERE created_at < ?", before.UnixNano())
+	return err
+}
+
+// PutJoinRecord 实现保存进入服务器记录
+func (s *SQLStore) PutJoinRecord(ctx context.Context, record JoinRecord) error {
+	return s.replace(ctx,
+		"DELETE FROM join_records WHERE server_id = ?", []any{record.ServerID},
+		"INSERT INTO join_records (server_id, profile_id, ip, created_at) VALUES (?, ?, ?, ?)",
+		record.ServerID, record.ProfileID, record.IP, record.CreatedAt.UnixNano())
+}
+
+// GetJoinRecord 实现根据serverId查询进入服务器记录
+func (s *SQLStore) GetJoinRecord(ctx context.Context, serverID string) (*JoinRecord, error) {
+	var record JoinRecord
+	var createdAt int64
+	err := s.DB.QueryRowContext(ctx, s.Dialect.rebind("SELECT server_id, profile_id, ip, created_at FROM join_records WHERE server_id = ?"), serverID).
+		Scan(&record.ServerID, &record.ProfileID, &record.IP, &createdAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	record.CreatedAt = time.Unix(0, createdAt)
+	return &record, nil
+}
+
+// DeleteJoinRecordsBefore 实现删除创建时间早于before的进入服务器记录
+func (s *SQLStore) DeleteJoinRecordsBefore(ctx context.Context, before time.Time) error {
+	_, err := s.exec(ctx, "DELETE FROM join_records WHERE created_at < ?", before.UnixNano())
+	return err
+}
+
+// SetProfileTexture 实现设置角色的材质
+func (s *SQLStore) SetProfileTexture(ctx context.Context, texture ProfileTexture) error {
+	return s.replace(ctx,
+		"DELETE FROM profile_textures WHERE profile_id = ? AND texture_type = ?", []any{texture.ProfileID, string(texture.Type)},
+		"INSERT INTO profile_textures (profile_id, texture_type, hash, model) VALUES (?, ?, ?, ?)",
+		texture.ProfileID, string(texture.Type), texture.Hash, string(texture.Model))
+}
+
+// GetProfileTextures 实现返回角色的全部材质
+func (s *SQLStore) GetProfileTextures(ctx context.Context, profileID string) ([]ProfileTexture, error) {
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.rebind("SELECT profile_id, texture_type, hash, model FROM profile_textures WHERE profile_id = ?"), profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var textures []ProfileTexture
+	for rows.Next() {
+		var texture ProfileTexture
+		if err := rows.Scan(&texture.ProfileID, &texture.Type, &texture.Hash, &texture.Model); err != nil {
+			return nil, err
+		}
+		textures = append(textures, texture)
+	}
+	return textures, rows.Err()
+}
+
+// DeleteProfileTexture 实现删除角色的材质
+func (s *SQLStore) DeleteProfileTexture(ctx context.Context, profileID string, textureType models.TextureType) error {
+	n, err := s.exec(ctx, "DELETE FROM profile_textures WHERE profile_id = ? AND texture_type = ?", profileID, string(textureType))
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// TextureInUse 实现检查材质是否仍被任何角色使用
+func (s *SQLStore) TextureInUse(ctx context.Context, hash string) (bool, error) {
+	return s.exists(ctx, "SELECT 1 FROM profile_textures WHERE hash = ? LIMIT 1", hash)
+}
+
+// replace 在事务中先删除再插入，用于覆盖已有的记录（各方言的upsert语法不同）
+func (s *SQLStore) replace(ctx context.Context, deleteQuery string, deleteArgs []any, insertQuery string, insertArgs ...any) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.Dialect.rebind(deleteQuery), deleteArgs...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.Dialect.rebind(insertQuery), insertArgs...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
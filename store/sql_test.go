@@ -0,0 +1,68 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{DialectSQLite, "SELECT 1 FROM users WHERE id = ? AND name = ?", "SELECT 1 FROM users WHERE id = ? AND name = ?"},
+		{DialectMySQL, "SELECT 1 FROM users WHERE id = ? AND name = ?", "SELECT 1 FROM users WHERE id = ? AND name = ?"},
+		{DialectPostgres, "SELECT 1 FROM users WHERE id = ? AND name = ?", "SELECT 1 FROM users WHERE id = $1 AND name = $2"},
+		{DialectPostgres, "INSERT INTO t (a, b, c, d, e, f, g, h, i, j, k) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", "INSERT INTO t (a, b, c, d, e, f, g, h, i, j, k) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"},
+		{DialectPostgres, "DELETE FROM access_tokens", "DELETE FROM access_tokens"},
+		{DialectPostgres, "SELECT '名称' FROM t WHERE a=?", "SELECT '名称' FROM t WHERE a=$1"},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.rebind(tt.query); got != tt.want {
+			t.Errorf("Dialect(%d).rebind(%q) = %q, want %q", tt.dialect, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"Empty", "", nil},
+		{"OnlyComments", "-- 注释\n  -- 缩进的注释\n", nil},
+		{"Single", "CREATE TABLE t (a INT)", []string{"CREATE TABLE t (a INT)"}},
+		{
+			"Multiple",
+			"-- 表结构\nCREATE TABLE t (\n\ta INT, -- 列\n\tb INT\n);\n\n\t-- 索引\nCREATE INDEX i ON t (a);\n",
+			[]string{"CREATE TABLE t (\n\ta INT, -- 列\n\tb INT\n)", "CREATE INDEX i ON t (a)"},
+		},
+		{"EmptyStatements", ";; CREATE TABLE t (a INT) ;\n;", []string{"CREATE TABLE t (a INT)"}},
+		{"CRLF", "CREATE TABLE t (a INT);\r\n-- 注释\r\nCREATE INDEX i ON t (a);\r\n", []string{"CREATE TABLE t (a INT)", "CREATE INDEX i ON t (a)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStatementsInitMigration(t *testing.T) {
+	script, err := migrations.ReadFile("migrations/0001_init.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements := splitStatements(string(script))
+	if len(statements) != 9 {
+		t.Fatalf("0001_init.sql split into %d statements, want 9", len(statements))
+	}
+	for _, statement := range statements {
+		if statement[0] == '-' {
+			t.Errorf("statement starts with a comment: %q", statement)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+//go:build sqlite
+
+// 使用SQLite运行SQLStore的测试：go test -tags sqlite ./store
+// 驱动github.com/mattn/go-sqlite3需要cgo
+
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openSQLiteStore 在临时目录中打开SQLite数据库，测试结束时关闭
+func openSQLiteStore(t *testing.T) *SQLStore {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "yggdrasil.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewSQLStore(db, DialectSQLite)
+}
+
+func TestSQLStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s := openSQLiteStore(t)
+		if err := s.Migrate(context.Background()); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+		return s
+	})
+}
+
+func TestSQLStoreMigrateTwice(t *testing.T) {
+	s := openSQLiteStore(t)
+	for i := 0; i < 2; i++ {
+		if err := s.Migrate(context.Background()); err != nil {
+			t.Fatalf("Migrate %d: %v", i, err)
+		}
+	}
+}
+
+// appliedSteps 返回测试迁移脚本写入steps表的值
+func appliedSteps(t *testing.T, s *SQLStore) []int {
+	t.Helper()
+	rows, err := s.DB.Query("SELECT step FROM steps ORDER BY rowid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var steps []int
+	for rows.Next() {
+		var step int
+		if err := rows.Scan(&step); err != nil {
+			t.Fatal(err)
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func TestSQLStoreMigrateOrder(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLiteStore(t)
+
+	// 按文件名排序时10_会在2_之前执行，此时steps表尚不存在
+	fsys := fstest.MapFS{
+		"migrations/10_third.sql":    {Data: []byte("INSERT INTO steps (step) VALUES (10);")},
+		"migrations/2_first.sql":     {Data: []byte("-- 创建表\nCREATE TABLE steps (step INT NOT NULL);\nINSERT INTO steps (step) VALUES (2);")},
+		"migrations/0003_second.sql": {Data: []byte("INSERT INTO steps (step) VALUES (3);")},
+		"migrations/README.md":       {Data: []byte("not a migration")},
+	}
+	if err := s.migrateFS(ctx, fsys); err != nil {
+		t.Fatalf("migrateFS: %v", err)
+	}
+	if steps := appliedSteps(t, s); !reflect.DeepEqual(steps, []int{2, 3, 10}) {
+		t.Fatalf("steps = %v, want [2 3 10]", steps)
+	}
+
+	// 已执行的版本不会再次执行，包括被其他方式标记为已执行的版本
+	if _, err := s.DB.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (12, 0)"); err != nil {
+		t.Fatal(err)
+	}
+	fsys["migrations/11_fourth.sql"] = &fstest.MapFile{Data: []byte("INSERT INTO steps (step) VALUES (11);")}
+	fsys["migrations/12_applied.sql"] = &fstest.MapFile{Data: []byte("INSERT INTO missing (step) VALUES (12);")}
+	if err := s.migrateFS(ctx, fsys); err != nil {
+		t.Fatalf("second migrateFS: %v", err)
+	}
+	if steps := appliedSteps(t, s); !reflect.DeepEqual(steps, []int{2, 3, 10, 11}) {
+		t.Fatalf("steps = %v, want [2 3 10 11]", steps)
+	}
+
+	// 失败的迁移整体回滚，也不会被记录为已执行
+	fsys["migrations/13_broken.sql"] = &fstest.MapFile{Data: []byte("INSERT INTO steps (step) VALUES (13);\nINSERT INTO missing (step) VALUES (13);")}
+	if err := s.migrateFS(ctx, fsys); err == nil {
+		t.Fatal("broken migration succeeded")
+	}
+	if steps := appliedSteps(t, s); !reflect.DeepEqual(steps, []int{2, 3, 10, 11}) {
+		t.Fatalf("steps after a failed migration = %v, want [2 3 10 11]", steps)
+	}
+	var n int
+	if err := s.DB.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = 13").Scan(&n); err != nil || n != 0 {
+		t.Fatalf("failed migration recorded: %d, %v", n, err)
+	}
+}
+
+func TestSQLStoreMigrateInvalidName(t *testing.T) {
+	s := openSQLiteStore(t)
+	fsys := fstest.MapFS{"migrations/init.sql": {Data: []byte("CREATE TABLE t (a INT);")}}
+	if err := s.migrateFS(context.Background(), fsys); err == nil {
+		t.Fatal("migration without a version succeeded")
+	}
+}
+
+func TestSQLStoreInsertError(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLiteStore(t)
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 与已有记录冲突时返回ErrConflict
+	mustCreateUser(t, s, "alice")
+	if err := s.CreateUser(ctx, UserCredentials{ID: "other", Username: "ALICE"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("CreateUser duplicate = %v, want ErrConflict", err)
+	}
+
+	// 其他原因导致插入失败时返回原始错误，而不是ErrConflict
+	if _, err := s.DB.Exec("DROP TABLE profiles"); err != nil {
+		t.Fatal(err)
+	}
+	err := s.CreateProfile(ctx, ProfileInfo{ID: "p1", UserID: "id-alice", Name: "Alice"})
+	if err == nil || errors.Is(err, ErrConflict) {
+		t.Fatalf("CreateProfile without the table = %v, want the driver error", err)
+	}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// 存储层返回的错误
+var (
+	ErrNotFound = errors.New("record not found")      // 记录不存在
+	ErrConflict = errors.New("record already exists") // 违反唯一性约束（用户名、角色UUID或角色名称重复）
+)
+
+// Store 定义Yggdrasil服务所需的全部存储，服务层的协议逻辑只依赖该接口
+// 实现需要保证并发安全
+
+type Store interface {
+	UserStore
+	ProfileStore
+	TokenStore
+	JoinRecordStore
+	ProfileTextureStore
+}
+
+// UserStore 定义用户的存储接口
+
+type UserStore interface {
+	// CreateUser 创建用户，用户名（不区分大小写）已存在时返回ErrConflict
+	CreateUser(ctx context.Context, user UserCredentials) error
+
+	// GetUser 根据用户名查询用户（不区分大小写），用户不存在时返回ErrNotFound
+	GetUser(ctx context.Context, username string) (*UserCredentials, error)
+
+	// UpdateUser 更新用户的凭证，用户不存在时返回ErrNotFound
+	UpdateUser(ctx context.Context, user UserCredentials) error
+}
+
+// ProfileStore 定义角色的存储接口
+
+type ProfileStore interface {
+	// CreateProfile 创建角色，角色UUID或名称（不区分大小写）已存在时返回ErrConflict
+	CreateProfile(ctx context.Context, profile ProfileInfo) error
+
+	// GetProfile 根据UUID查询角色，角色不存在时返回ErrNotFound
+	GetProfile(ctx context.Context, id string) (*ProfileInfo, error)
+
+	// GetProfileByName 根据名称查询角色（不区分大小写），角色不存在时返回ErrNotFound
+	GetProfileByName(ctx context.Context, name string) (*ProfileInfo, error)
+
+	// ListProfiles 按创建顺序返回用户的全部角色
+	ListProfiles(ctx context.Context, userID string) ([]ProfileInfo, error)
+}
+
+// TokenStore 定义访问令牌的存储接口
+
+type TokenStore interface {
+	// CreateToken 保存访问令牌
+	CreateToken(ctx context.Context, token AccessTokenInfo) error
+
+	// GetToken 查询访问令牌，令牌不存在时返回ErrNotFound
+	GetToken(ctx context.Context, accessToken string) (*AccessTokenInfo, error)
+
+	// ListTokens 按签发时间升序返回用户的全部访问令牌
+	ListTokens(ctx context.Context, userID string) ([]AccessTokenInfo, error)
+
+	// DeleteToken 删除访问令牌，令牌不存在时返回ErrNotFound
+	// 并发删除同一个令牌时只有一方成功，服务层据此保证令牌只能被刷新一次
+	DeleteToken(ctx context.Context, accessToken string) error
+
+	// DeleteUserTokens 删除用户的全部访问令牌
+	DeleteUserTokens(ctx context.Context, userID string) error
+
+	// DeleteTokensBefore 删除签发时间早于before的访问令牌
+	DeleteTokensBefore(ctx context.Context, before time.Time) error
+}
+
+// JoinRecordStore 定义客户端进入服务器记录的存储接口
+
+type JoinRecordStore interface {
+	// PutJoinRecord 保存进入服务器记录，serverId相同的记录会被覆盖
+	PutJoinRecord(ctx context.Context, record JoinRecord) error
+
+	// GetJoinRecord 根据serverId查询进入服务器记录，记录不存在时返回ErrNotFound
+	GetJoinRecord(ctx context.Context, serverID string) (*JoinRecord, error)
+
+	// DeleteJoinRecordsBefore 删除创建时间早于before的进入服务器记录
+	DeleteJoinRecordsBefore(ctx context.Context, before time.Time) error
+}
+
+// ProfileTextureStore 定义角色材质信息的存储接口，材质文件本身保存在textures.Store中
+
+type ProfileTextureStore interface {
+	// SetProfileTexture 设置角色的材质，同类型的材质会被覆盖
+	SetProfileTexture(ctx context.Context, texture ProfileTexture) error
+
+	// GetProfileTextures 返回角色的全部材质
+	GetProfileTextures(ctx context.Context, profileID string) ([]ProfileTexture, error)
+
+	// DeleteProfileTexture 删除角色的材质，材质不存在时返回ErrNotFound
+	DeleteProfileTexture(ctx context.Context, profileID string, textureType models.TextureType) error
+
+	// TextureInUse 检查材质是否仍被任何角色使用
+	TextureInUse(ctx context.Context, hash string) (bool, error)
+}
+
+// UserCredentials 表示用户凭证
+
+type UserCredentials struct {
+	ID       string
+	Username string
+	Password string // 加盐的密码哈希，见passwords包
+}
+
+// ProfileInfo 表示角色及其所有者
+
+type ProfileInfo struct {
+	ID        string
+	UserID    string
+	Name      string
+	CreatedAt time.Time
+}
+
+// AccessTokenInfo 表示访问令牌信息
+
+type AccessTokenInfo struct {
+	AccessToken string
+	ClientToken string
+	UserID      string
+	ProfileID   string // 令牌绑定的角色UUID，未绑定时为空
+	CreatedAt   time.Time
+}
+
+// JoinRecord 表示客户端进入服务器的记录
+
+type JoinRecord struct {
+	ServerID  string
+	ProfileID string
+	IP        string
+	CreatedAt time.Time
+}
+
+// ProfileTexture 表示角色的材质
+
+type ProfileTexture struct {
+	ProfileID string
+	Type      models.TextureType
+	Hash      string
+	Model     models.TextureModel
+}
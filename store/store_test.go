@@ -0,0 +1,236 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// testStore 检查Store实现是否符合接口约定，newStore每次返回一个空的存储
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStore(t)) })
+	t.Run("Profiles", func(t *testing.T) { testProfiles(t, newStore(t)) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, newStore(t)) })
+	t.Run("JoinRecords", func(t *testing.T) { testJoinRecords(t, newStore(t)) })
+	t.Run("ProfileTextures", func(t *testing.T) { testProfileTextures(t, newStore(t)) })
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return NewMemoryStore() })
+}
+
+func TestFileStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return openFileStore(t, t.TempDir()) })
+}
+
+func testUsers(t *testing.T, s Store) {
+	ctx := context.Background()
+	mustCreateUser(t, s, "Alice@example.com")
+	if err := s.CreateUser(ctx, UserCredentials{ID: "other", Username: "alice@EXAMPLE.com"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("CreateUser differing only in case = %v, want ErrConflict", err)
+	}
+
+	user, err := s.GetUser(ctx, "ALICE@example.com")
+	if err != nil || user.ID != "id-Alice@example.com" || user.Username != "Alice@example.com" {
+		t.Fatalf("GetUser = %+v, %v", user, err)
+	}
+	user.Password = "rehashed"
+	if err := s.UpdateUser(ctx, *user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if user, err := s.GetUser(ctx, "alice@example.com"); err != nil || user.Password != "rehashed" {
+		t.Fatalf("GetUser after update = %+v, %v", user, err)
+	}
+
+	assertUser(t, s, "bob", false)
+	if err := s.UpdateUser(ctx, UserCredentials{ID: "id-bob", Username: "bob"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("UpdateUser missing = %v, want ErrNotFound", err)
+	}
+}
+
+func testProfiles(t *testing.T, s Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	mustCreateUser(t, s, "alice")
+
+	for i, name := range []string{"First", "Second"} {
+		profile := ProfileInfo{ID: "p-" + name, UserID: "id-alice", Name: name, CreatedAt: now.Add(time.Duration(i) * time.Minute)}
+		if err := s.CreateProfile(ctx, profile); err != nil {
+			t.Fatalf("CreateProfile(%s): %v", name, err)
+		}
+	}
+	for _, profile := range []ProfileInfo{
+		{ID: "p-First", UserID: "id-alice", Name: "Other"},
+		{ID: "p-other", UserID: "id-alice", Name: "FIRST"},
+	} {
+		if err := s.CreateProfile(ctx, profile); !errors.Is(err, ErrConflict) {
+			t.Fatalf("CreateProfile(%+v) = %v, want ErrConflict", profile, err)
+		}
+	}
+
+	if profile, err := s.GetProfile(ctx, "p-First"); err != nil || profile.Name != "First" || profile.UserID != "id-alice" {
+		t.Fatalf("GetProfile = %+v, %v", profile, err)
+	}
+	if profile, err := s.GetProfileByName(ctx, "first"); err != nil || profile.ID != "p-First" {
+		t.Fatalf("GetProfileByName = %+v, %v", profile, err)
+	}
+	if _, err := s.GetProfile(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetProfile missing = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetProfileByName(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetProfileByName missing = %v, want ErrNotFound", err)
+	}
+
+	profiles, err := s.ListProfiles(ctx, "id-alice")
+	if err != nil || len(profiles) != 2 || profiles[0].ID != "p-First" || profiles[1].ID != "p-Second" {
+		t.Fatalf("ListProfiles = %+v, %v", profiles, err)
+	}
+}
+
+func testTokens(t *testing.T, s Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	// 写入顺序与签发时间不同，ListTokens必须按签发时间升序返回，服务层据此吊销最早签发的令牌
+	for _, token := range []AccessTokenInfo{
+		{AccessToken: "t2", ClientToken: "c2", UserID: "alice", CreatedAt: now.Add(-2 * time.Minute)},
+		{AccessToken: "t3", ClientToken: "c3", UserID: "alice", ProfileID: "p1", CreatedAt: now.Add(-time.Minute)},
+		{AccessToken: "t1", ClientToken: "c1", UserID: "alice", CreatedAt: now.Add(-3 * time.Minute)},
+		{AccessToken: "b1", ClientToken: "c1", UserID: "bob", CreatedAt: now.Add(-4 * time.Minute)},
+	} {
+		if err := s.CreateToken(ctx, token); err != nil {
+			t.Fatalf("CreateToken(%s): %v", token.AccessToken, err)
+		}
+	}
+	assertTokens(t, s, "alice", "t1", "t2", "t3")
+
+	token, err := s.GetToken(ctx, "t3")
+	if err != nil || token.ClientToken != "c3" || token.UserID != "alice" || token.ProfileID != "p1" || !token.CreatedAt.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("GetToken = %+v, %v", token, err)
+	}
+
+	// 同一个令牌只能删除一次
+	if err := s.DeleteToken(ctx, "t2"); err != nil {
+		t.Fatalf("DeleteToken: %v", err)
+	}
+	if err := s.DeleteToken(ctx, "t2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second DeleteToken = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetToken(ctx, "t2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetToken deleted = %v, want ErrNotFound", err)
+	}
+
+	if err := s.DeleteTokensBefore(ctx, now.Add(-90*time.Second)); err != nil {
+		t.Fatalf("DeleteTokensBefore: %v", err)
+	}
+	assertTokens(t, s, "alice", "t3")
+	assertTokens(t, s, "bob")
+
+	if err := s.CreateToken(ctx, AccessTokenInfo{AccessToken: "b2", UserID: "bob", CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUserTokens(ctx, "alice"); err != nil {
+		t.Fatalf("DeleteUserTokens: %v", err)
+	}
+	assertTokens(t, s, "alice")
+	assertTokens(t, s, "bob", "b2")
+}
+
+// assertTokens 检查ListTokens返回的令牌及其顺序
+func assertTokens(t *testing.T, s Store, userID string, want ...string) {
+	t.Helper()
+	tokens, err := s.ListTokens(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("ListTokens(%s): %v", userID, err)
+	}
+	got := make([]string, len(tokens))
+	for i, token := range tokens {
+		got[i] = token.AccessToken
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ListTokens(%s) = %v, want %v", userID, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("ListTokens(%s) = %v, want %v", userID, got, want)
+		}
+	}
+}
+
+func testJoinRecords(t *testing.T, s Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for _, record := range []JoinRecord{
+		{ServerID: "s1", ProfileID: "p1", IP: "198.51.100.1", CreatedAt: now.Add(-time.Minute)},
+		{ServerID: "s1", ProfileID: "p2", IP: "198.51.100.2", CreatedAt: now},
+		{ServerID: "s2", ProfileID: "p3", CreatedAt: now.Add(-time.Minute)},
+	} {
+		if err := s.PutJoinRecord(ctx, record); err != nil {
+			t.Fatalf("PutJoinRecord: %v", err)
+		}
+	}
+
+	// serverId相同的记录被覆盖
+	record, err := s.GetJoinRecord(ctx, "s1")
+	if err != nil || record.ProfileID != "p2" || record.IP != "198.51.100.2" {
+		t.Fatalf("GetJoinRecord = %+v, %v", record, err)
+	}
+
+	if err := s.DeleteJoinRecordsBefore(ctx, now.Add(-time.Second)); err != nil {
+		t.Fatalf("DeleteJoinRecordsBefore: %v", err)
+	}
+	if _, err := s.GetJoinRecord(ctx, "s2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetJoinRecord expired = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetJoinRecord(ctx, "s1"); err != nil {
+		t.Fatalf("GetJoinRecord kept: %v", err)
+	}
+}
+
+func testProfileTextures(t *testing.T, s Store) {
+	ctx := context.Background()
+	mustCreateUser(t, s, "alice")
+	for _, id := range []string{"p1", "p2"} {
+		if err := s.CreateProfile(ctx, ProfileInfo{ID: id, UserID: "id-alice", Name: "Name" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, texture := range []ProfileTexture{
+		{ProfileID: "p1", Type: models.TextureSkin, Hash: "old"},
+		{ProfileID: "p1", Type: models.TextureSkin, Hash: "shared", Model: models.TextureModelSlim},
+		{ProfileID: "p1", Type: models.TextureCape, Hash: "cape"},
+		{ProfileID: "p2", Type: models.TextureSkin, Hash: "shared"},
+	} {
+		if err := s.SetProfileTexture(ctx, texture); err != nil {
+			t.Fatalf("SetProfileTexture: %v", err)
+		}
+	}
+
+	// 同类型的材质被覆盖
+	profileTextures, err := s.GetProfileTextures(ctx, "p1")
+	if err != nil || len(profileTextures) != 2 {
+		t.Fatalf("GetProfileTextures = %+v, %v", profileTextures, err)
+	}
+	for _, texture := range profileTextures {
+		if texture.Type == models.TextureSkin && (texture.Hash != "shared" || texture.Model != models.TextureModelSlim) {
+			t.Fatalf("skin = %+v", texture)
+		}
+	}
+	if inUse, err := s.TextureInUse(ctx, "old"); err != nil || inUse {
+		t.Fatalf("TextureInUse(old) = %v, %v, want false", inUse, err)
+	}
+
+	// 材质仍被其他角色使用
+	if err := s.DeleteProfileTexture(ctx, "p1", models.TextureSkin); err != nil {
+		t.Fatalf("DeleteProfileTexture: %v", err)
+	}
+	if err := s.DeleteProfileTexture(ctx, "p1", models.TextureSkin); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second DeleteProfileTexture = %v, want ErrNotFound", err)
+	}
+	if inUse, err := s.TextureInUse(ctx, "shared"); err != nil || !inUse {
+		t.Fatalf("TextureInUse(shared) = %v, %v, want true", inUse, err)
+	}
+}
@@ -0,0 +1,145 @@
+package textures
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// 材质文件的限制
+const (
+	MaxFileSize  = 1 << 20 // 材质文件的最大长度
+	MaxDimension = 1024    // 材质图片的最大宽度和高度（像素）
+)
+
+// ErrInvalidTexture 表示材质文件无效（格式错误、尺寸不符合要求等）
+var ErrInvalidTexture = errors.New("invalid texture")
+
+// Normalize 校验并规范化上传的材质
+// 旧版64x32皮肤会被转换为64x64，图片会被重新编码以去除元数据
+// 返回重新编码后的PNG数据及其材质哈希
+func Normalize(data []byte, textureType models.TextureType) ([]byte, string, error) {
+	if len(data) > MaxFileSize {
+		return nil, "", fmt.Errorf("%w: file is larger than %d bytes", ErrInvalidTexture, MaxFileSize)
+	}
+
+	// 先读取图片尺寸再解码，避免解码超大图片（解压炸弹）
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidTexture, err)
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return nil, "", fmt.Errorf("%w: image is larger than %dx%d", ErrInvalidTexture, MaxDimension, MaxDimension)
+	}
+	if !validDimensions(textureType, config.Width, config.Height) {
+		return nil, "", fmt.Errorf("%w: %dx%d is not a valid %s size", ErrInvalidTexture, config.Width, config.Height, textureType)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidTexture, err)
+	}
+
+	// 统一转换为非预乘的RGBA格式
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	if textureType == models.TextureSkin && nrgba.Bounds().Dy()*2 == nrgba.Bounds().Dx() {
+		nrgba = convertLegacySkin(nrgba)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, nrgba); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), Hash(nrgba), nil
+}
+
+// validDimensions 检查材质的尺寸是否符合要求
+// 皮肤：64x32或64x64及其整数倍（高清皮肤）
+// 披风：64x32或22x17及其整数倍
+func validDimensions(textureType models.TextureType, width, height int) bool {
+	switch textureType {
+	case models.TextureSkin:
+		return width > 0 && width%64 == 0 && (height == width || height*2 == width)
+	case models.TextureCape:
+		if width > 0 && width%64 == 0 && height*2 == width {
+			return true
+		}
+		return width > 0 && width%22 == 0 && height*22 == width*17
+	default:
+		return false
+	}
+}
+
+// convertLegacySkin 将旧版64x32格式的皮肤转换为64x64格式
+// 左臂和左腿由右臂和右腿水平翻转得到，与Minecraft客户端的处理方式一致
+func convertLegacySkin(legacy *image.NRGBA) *image.NRGBA {
+	width := legacy.Bounds().Dx()
+	scale := width / 64
+
+	skin := image.NewNRGBA(image.Rect(0, 0, width, width))
+	draw.Draw(skin, legacy.Bounds(), legacy, image.Point{}, draw.Src)
+
+	// copyArea 将(x, y)处w*h的区域水平翻转后复制到(x+dx, y+dy)处
+	copyArea := func(x, y, dx, dy, w, h int) {
+		x, y, dx, dy, w, h = x*scale, y*scale, dx*scale, dy*scale, w*scale, h*scale
+		for i := 0; i < h; i++ {
+			for j := 0; j < w; j++ {
+				skin.SetNRGBA(x+dx+w-1-j, y+dy+i, skin.NRGBAAt(x+j, y+i))
+			}
+		}
+	}
+
+	// 左腿
+	copyArea(4, 16, 16, 32, 4, 4)
+	copyArea(8, 16, 16, 32, 4, 4)
+	copyArea(0, 20, 24, 32, 4, 12)
+	copyArea(4, 20, 16, 32, 4, 12)
+	copyArea(8, 20, 8, 32, 4, 12)
+	copyArea(12, 20, 16, 32, 4, 12)
+	// 左臂
+	copyArea(44, 16, -8, 32, 4, 4)
+	copyArea(48, 16, -8, 32, 4, 4)
+	copyArea(40, 20, 0, 32, 4, 12)
+	copyArea(44, 20, -8, 32, 4, 12)
+	copyArea(48, 20, -16, 32, 4, 12)
+	copyArea(52, 20, -8, 32, 4, 12)
+
+	return skin
+}
+
+// Hash 计算材质的哈希值
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E6%9D%90%E8%B4%A8-url-%E8%A7%84%E8%8C%83
+func Hash(img *image.NRGBA) string {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// 依次写入宽度、高度和按列排列的ARGB像素，完全透明的像素写入0
+	buf := make([]byte, 8+width*height*4)
+	binary.BigEndian.PutUint32(buf[0:], uint32(width))
+	binary.BigEndian.PutUint32(buf[4:], uint32(height))
+	pos := 8
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			c := img.NRGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+			if c.A != 0 {
+				buf[pos], buf[pos+1], buf[pos+2], buf[pos+3] = c.A, c.R, c.G, c.B
+			}
+			pos += 4
+		}
+	}
+
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
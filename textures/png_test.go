@@ -0,0 +1,191 @@
+package textures
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// testPixel 测试图片(x, y)处的像素，部分像素完全透明但RGB不为0
+func testPixel(x, y int) color.NRGBA {
+	a := uint8(255 - (x*3+y)%100)
+	if (x+y)%7 == 0 {
+		a = 0
+	}
+	return color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8(x + y), A: a}
+}
+
+// testImage 创建width*height的测试图片
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.SetNRGBA(x, y, testPixel(x, y))
+		}
+	}
+	return img
+}
+
+// encodePNG 将图片编码为PNG
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 已知答案的材质哈希，由按技术规范独立实现的程序计算
+const (
+	testImageHash64x32 = "79a2d632cfba1d76e804e8fc3ca2ea536f59224972429e94ca60bb1fde2b13e1"
+	testImageHash64x64 = "ad8c225a2dc408d2299b23e9bddbaac58e2144a87e9953e93d565b20f35a00fe"
+
+	// 64x32的测试图片转换为64x64皮肤后的哈希
+	convertedLegacySkinHash = "1d4960402e84a4b85ce374d5a9ab10df842ee4961bfa97a5697df576cdfabb79"
+)
+
+func TestHashKnownAnswer(t *testing.T) {
+	if got := Hash(testImage(64, 32)); got != testImageHash64x32 {
+		t.Errorf("Hash(64x32) = %s, want %s", got, testImageHash64x32)
+	}
+	if got := Hash(testImage(64, 64)); got != testImageHash64x64 {
+		t.Errorf("Hash(64x64) = %s, want %s", got, testImageHash64x64)
+	}
+}
+
+func TestHashIgnoresOffset(t *testing.T) {
+	// 子图片的原点不在(0, 0)时，哈希与单独的图片相同
+	big := image.NewNRGBA(image.Rect(0, 0, 80, 80))
+	sub := big.SubImage(image.Rect(8, 8, 72, 72)).(*image.NRGBA)
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			sub.SetNRGBA(8+x, 8+y, testPixel(x, y))
+		}
+	}
+	if got := Hash(sub); got != testImageHash64x64 {
+		t.Errorf("Hash(sub image) = %s, want %s", got, testImageHash64x64)
+	}
+}
+
+func TestNormalizeSkin(t *testing.T) {
+	data, hash, err := Normalize(encodePNG(t, testImage(64, 64)), models.TextureSkin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != testImageHash64x64 {
+		t.Errorf("hash = %s, want %s", hash, testImageHash64x64)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Errorf("normalized size = %v", b)
+	}
+}
+
+func TestNormalizeLegacySkin(t *testing.T) {
+	data, hash, err := Normalize(encodePNG(t, testImage(64, 32)), models.TextureSkin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != convertedLegacySkinHash {
+		t.Errorf("hash = %s, want %s", hash, convertedLegacySkinHash)
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skin, ok := decoded.(*image.NRGBA)
+	if !ok || skin.Bounds() != image.Rect(0, 0, 64, 64) {
+		t.Fatalf("converted skin = %T %v", decoded, decoded.Bounds())
+	}
+
+	// 右腿正面(4, 20)-(8, 32)水平翻转后成为左腿正面(20, 52)-(24, 64)
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 4; x++ {
+			want := testPixel(4+x, 20+y)
+			if got := skin.NRGBAAt(23-x, 52+y); got != want {
+				t.Fatalf("left leg front (%d, %d) = %v, want %v", 23-x, 52+y, got, want)
+			}
+		}
+	}
+	// 右臂正面(44, 20)-(48, 32)水平翻转后成为左臂正面(36, 52)-(40, 64)
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 4; x++ {
+			want := testPixel(44+x, 20+y)
+			if got := skin.NRGBAAt(39-x, 52+y); got != want {
+				t.Fatalf("left arm front (%d, %d) = %v, want %v", 39-x, 52+y, got, want)
+			}
+		}
+	}
+	// 原有的上半部分保持不变
+	if got, want := skin.NRGBAAt(10, 10), testPixel(10, 10); got != want {
+		t.Fatalf("head pixel = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeDimensions(t *testing.T) {
+	tests := []struct {
+		textureType   models.TextureType
+		width, height int
+		valid         bool
+	}{
+		{models.TextureSkin, 64, 32, true},
+		{models.TextureSkin, 64, 64, true},
+		{models.TextureSkin, 128, 128, true},
+		{models.TextureSkin, 128, 64, true},
+		{models.TextureSkin, 22, 17, false},
+		{models.TextureSkin, 44, 34, false},
+		{models.TextureSkin, 64, 48, false},
+		{models.TextureSkin, 96, 96, false},
+		{models.TextureCape, 64, 32, true},
+		{models.TextureCape, 128, 64, true},
+		{models.TextureCape, 22, 17, true},
+		{models.TextureCape, 44, 34, true},
+		{models.TextureCape, 64, 64, false},
+		{models.TextureCape, 128, 128, false},
+		{models.TextureCape, 23, 17, false},
+		{models.TextureCape, 22, 18, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s_%dx%d", tt.textureType, tt.width, tt.height), func(t *testing.T) {
+			_, _, err := Normalize(encodePNG(t, testImage(tt.width, tt.height)), tt.textureType)
+			if tt.valid && err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidTexture) {
+				t.Fatalf("Normalize error = %v, want ErrInvalidTexture", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeRejectsInvalidInput(t *testing.T) {
+	valid := encodePNG(t, testImage(64, 64))
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"OverSize", append(bytes.Clone(valid), make([]byte, MaxFileSize)...)},
+		{"OverDimension", encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 2*MaxDimension, 2*MaxDimension)))},
+		{"NotPNG", []byte("GIF89a not a png")},
+		{"Truncated", valid[:len(valid)/2]},
+		{"Empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Normalize(tt.data, models.TextureSkin); !errors.Is(err, ErrInvalidTexture) {
+				t.Fatalf("Normalize error = %v, want ErrInvalidTexture", err)
+			}
+		})
+	}
+}
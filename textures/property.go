@@ -0,0 +1,67 @@
+package textures
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/CycleZero/mc-yggdrasil-go/models"
+)
+
+// PropertyBuilder 用于生成角色的textures属性
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#textures-%E6%9D%90%E8%B4%A8%E4%BF%A1%E6%81%AF%E5%B1%9E%E6%80%A7
+
+type PropertyBuilder struct {
+	baseURL  string
+	profile  *models.Profile
+	textures map[models.TextureType]models.Texture
+}
+
+// NewPropertyBuilder 创建一个textures属性生成器
+// baseURL为材质服务的基础URL，材质的URL为 baseURL + "/textures/" + 哈希值
+func NewPropertyBuilder(baseURL string, profile *models.Profile) *PropertyBuilder {
+	return &PropertyBuilder{
+		baseURL:  baseURL,
+		profile:  profile,
+		textures: make(map[models.TextureType]models.Texture),
+	}
+}
+
+// URL 返回材质的URL
+func URL(baseURL, hash string) string {
+	return baseURL + "/textures/" + hash
+}
+
+// Skin 设置角色的皮肤，细手臂模型会被记录在材质的元数据中
+func (b *PropertyBuilder) Skin(hash string, model models.TextureModel) *PropertyBuilder {
+	texture := models.Texture{URL: URL(b.baseURL, hash)}
+	if model == models.TextureModelSlim {
+		texture.Metadata = map[string]string{"model": string(models.TextureModelSlim)}
+	}
+	b.textures[models.TextureSkin] = texture
+	return b
+}
+
+// Cape 设置角色的披风
+func (b *PropertyBuilder) Cape(hash string) *PropertyBuilder {
+	b.textures[models.TextureCape] = models.Texture{URL: URL(b.baseURL, hash)}
+	return b
+}
+
+// Build 生成Base64编码的textures属性
+func (b *PropertyBuilder) Build() (models.Property, error) {
+	payload, err := json.Marshal(models.TexturesPayload{
+		Timestamp:   time.Now().UnixMilli(),
+		ProfileID:   b.profile.ID,
+		ProfileName: b.profile.Name,
+		Textures:    b.textures,
+	})
+	if err != nil {
+		return models.Property{}, err
+	}
+
+	return models.Property{
+		Name:  "textures",
+		Value: base64.StdEncoding.EncodeToString(payload),
+	}, nil
+}
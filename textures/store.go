@@ -0,0 +1,156 @@
+package textures
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotFound 表示材质不存在
+var ErrNotFound = errors.New("texture not found")
+
+// Store 定义材质文件的存储接口，材质以哈希值寻址
+// https://github.com/yushijinhun/authlib-injector/wiki/Yggdrasil-%E6%9C%8D%E5%8A%A1%E7%AB%AF%E6%8A%80%E6%9C%AF%E8%A7%84%E8%8C%83#%E6%9D%90%E8%B4%A8-url-%E8%A7%84%E8%8C%83
+
+type Store interface {
+	// Put 保存材质，相同哈希值的材质会被覆盖
+	Put(hash string, data []byte) error
+
+	// Get 读取材质，材质不存在时返回ErrNotFound
+	Get(hash string) ([]byte, error)
+
+	// Delete 删除材质，材质不存在时不返回错误
+	Delete(hash string) error
+}
+
+// ValidHash 检查材质哈希值是否有效（64位小写十六进制字符串）
+func ValidHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// FileStore 是Store的文件系统实现，每个材质保存为目录下以哈希值命名的文件
+
+type FileStore struct {
+	Dir string // 材质文件所在的目录
+}
+
+// NewFileStore 创建一个新的文件系统材质存储，目录不存在时自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// Put 实现保存材质
+// 先写入临时文件再重命名，避免读取到写入一半的材质
+func (s *FileStore) Put(hash string, data []byte) error {
+	if !ValidHash(hash) {
+		return errors.New("invalid texture hash")
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, hash+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(hash))
+}
+
+// Get 实现读取材质
+func (s *FileStore) Get(hash string) ([]byte, error) {
+	if !ValidHash(hash) {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Delete 实现删除材质
+func (s *FileStore) Delete(hash string) error {
+	if !ValidHash(hash) {
+		return nil
+	}
+
+	err := os.Remove(s.path(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path 返回材质文件的路径
+func (s *FileStore) path(hash string) string {
+	return filepath.Join(s.Dir, hash+".png")
+}
+
+// MemoryStore 是Store的内存实现
+// 用于演示和测试
+
+type MemoryStore struct {
+	data map[string][]byte // 材质哈希 -> 材质数据
+	mu   sync.RWMutex
+}
+
+// NewMemoryStore 创建一个新的内存材质存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: make(map[string][]byte),
+	}
+}
+
+// Put 实现保存材质
+func (s *MemoryStore) Put(hash string, data []byte) error {
+	if !ValidHash(hash) {
+		return errors.New("invalid texture hash")
+	}
+
+	s.mu.Lock()
+	s.data[hash] = append([]byte(nil), data...)
+	s.mu.Unlock()
+	return nil
+}
+
+// Get 实现读取材质
+func (s *MemoryStore) Get(hash string) ([]byte, error) {
+	s.mu.RLock()
+	data, exists := s.data[hash]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// Delete 实现删除材质
+func (s *MemoryStore) Delete(hash string) error {
+	s.mu.Lock()
+	delete(s.data, hash)
+	s.mu.Unlock()
+	return nil
+}